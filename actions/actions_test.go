@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/gobuffalo/suite"
+	"github.com/ossn/fixme_backend/cache"
 )
 
 type ActionSuite struct {
@@ -12,6 +13,8 @@ type ActionSuite struct {
 }
 
 func Test_ActionSuite(t *testing.T) {
+	// The tests don't need a redis server
+	cache.SetBackend(cache.NewMemoryStore(1000))
 	action := suite.NewAction(App(context.TODO()))
 
 	as := &ActionSuite{
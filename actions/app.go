@@ -11,6 +11,7 @@ import (
 	tokenauth "github.com/gobuffalo/mw-tokenauth"
 	"github.com/gobuffalo/x/sessions"
 	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
 	"github.com/rs/cors"
 )
 
@@ -45,24 +46,44 @@ func App(ctx context.Context) *buffalo.App {
 		// Set the request content type to JSON
 		app.Use(contenttype.Set("application/json"))
 
+		// Invalidates the cached data the requests changed once their transaction is committed
+		app.Use(InvalidateAfterCommit)
+
 		// Wraps each request in a transaction.
 		//  c.Value("tx").(*pop.Connection)
 		// Remove to disable this.
 		app.Use(popmw.Transaction(models.DB))
 
-		app.GET("/projects", ProjectsResource{}.List)
-		app.GET("/repositories", RepositoriesResource{}.List)
-		app.GET("/issues", IssuesResource{}.ListOpen)
-		app.GET("/issues-count", IssuesResource{}.Count)
+		// Cache the landing page issues again whenever the worker flushes them
+		worker.AfterCacheFlush = warmIssuesCache
+
+		// Public GET routes can be revalidated with If-None-Match or If-Modified-Since
+		app.GET("/projects", ConditionalGet(ProjectsResource{}.List))
+		app.GET("/projects/{project_id}", ConditionalGet(ProjectsResource{}.Detail))
+		app.GET("/repositories", ConditionalGet(RepositoriesResource{}.List))
+		app.GET("/issues", ConditionalGet(IssuesResource{}.ListOpen))
+		app.GET("/issues/sorts", ConditionalGet(IssuesResource{}.Sorts))
+		app.GET("/issues/facets", ConditionalGet(IssuesResource{}.Facets))
+		app.GET("/issues-count", ConditionalGet(IssuesResource{}.Count))
 		app.POST("/login", AdminsResource{}.Login)
+		app.POST("/webhooks/github", GithubWebhook)
 
 		admin := app.Group("/admin")
 		admin.Use(tokenauth.New(tokenauth.Options{}))
 
 		admin.Resource("/projects", ProjectsResource{})
+		admin.GET("/projects/{project_id}/imports", RepositoryImportsResource{}.List)
+		admin.POST("/projects/{project_id}/imports", RepositoryImportsResource{}.Create)
+		admin.DELETE("/projects/{project_id}/imports/{import_id}", RepositoryImportsResource{}.Destroy)
 		admin.Resource("/repositories", RepositoriesResource{})
+		admin.POST("/repositories/{repository_id}/sync", RepositoriesResource{}.Sync)
 		admin.Resource("/issues", IssuesResource{})
 		admin.Resource("/users", AdminsResource{})
+		admin.GET("/cache/stats", CacheStats)
+		admin.GET("/jobs", SyncJobsResource{}.List)
+		admin.POST("/jobs/{job_id}/retry", SyncJobsResource{}.Retry)
+		admin.GET("/worker", WorkerStatus)
+		admin.GET("/worker/repositories", WorkerRepositories)
 	}
 	return app
 }
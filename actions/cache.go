@@ -0,0 +1,52 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/pkg/errors"
+)
+
+// pendingInvalidationsKey is the context key of the cache tags that are invalidated once the request is committed
+const pendingInvalidationsKey = "pending_cache_invalidations"
+
+// CacheStats returns how the cached issue listings, counts and facets were served. This function is mapped to the path
+// GET /admin/cache/stats
+func CacheStats(c buffalo.Context) error {
+	return c.Render(200, r.JSON(cache.FetchStats()))
+}
+
+// InvalidateAfterCommit invalidates the cache tags of a request once its transaction is committed,
+// so that the requests served meanwhile can't cache the previous rows again. It goes before the transaction middleware.
+func InvalidateAfterCommit(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		tags := &[]string{}
+		c.Set(pendingInvalidationsKey, tags)
+		if err := next(c); err != nil {
+			return err
+		}
+
+		// The transaction is rolled back along with the unsuccessful responses
+		if res, ok := c.Response().(*buffalo.Response); ok && (res.Status < 200 || res.Status >= 400) {
+			return nil
+		}
+		if len(*tags) > 0 {
+			if _, err := cache.Backend.Invalidate(*tags...); err != nil {
+				fmt.Println(errors.WithMessage(err, "cache invalidation failed"))
+			}
+		}
+		return nil
+	}
+}
+
+// Invalidates cache tags once the transaction of the request is committed
+func invalidateAfterCommit(c buffalo.Context, tags ...string) {
+	if pending, ok := c.Value(pendingInvalidationsKey).(*[]string); ok {
+		*pending = append(*pending, tags...)
+		return
+	}
+	if _, err := cache.Backend.Invalidate(tags...); err != nil {
+		fmt.Println(errors.WithMessage(err, "cache invalidation failed"))
+	}
+}
@@ -0,0 +1,43 @@
+package actions
+
+import (
+	"net/http/httptest"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/x/sessions"
+	"github.com/ossn/fixme_backend/cache"
+)
+
+func (as *ActionSuite) Test_InvalidateAfterCommit() {
+	as.NoError(cache.Backend.Set("project-detail", "cached", time.Minute, "project"))
+	cached := func() bool {
+		exists, err := cache.Backend.Exists("project-detail")
+		as.NoError(err)
+		return exists
+	}
+
+	app := buffalo.New(buffalo.Options{SessionStore: sessions.Null{}})
+	app.Use(InvalidateAfterCommit)
+	app.GET("/invalid", func(c buffalo.Context) error {
+		invalidateAfterCommit(c, "project")
+		return c.Render(422, r.JSON("invalid"))
+	})
+	app.GET("/saved", func(c buffalo.Context) error {
+		invalidateAfterCommit(c, "project")
+		// Nothing is invalidated while the transaction is open
+		as.True(cached())
+		return c.Render(200, r.JSON("saved"))
+	})
+
+	// The rolled back requests don't invalidate anything
+	res := httptest.NewRecorder()
+	app.ServeHTTP(res, httptest.NewRequest("GET", "/invalid", nil))
+	as.Equal(422, res.Code)
+	as.True(cached())
+
+	res = httptest.NewRecorder()
+	app.ServeHTTP(res, httptest.NewRequest("GET", "/saved", nil))
+	as.Equal(200, res.Code)
+	as.False(cached())
+}
@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/buffalo/render"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+)
+
+// lastModifiedKey is the context key handlers set the time their response last changed at
+const lastModifiedKey = "last_modified"
+
+// paginable is implemented by the paginators that set the X-Pagination header
+type paginable interface {
+	Paginate() string
+}
+
+// conditionalContext renders successful responses with an ETag and a Last-Modified header,
+// and answers with 304 when the client already has them
+type conditionalContext struct {
+	buffalo.Context
+}
+
+// ConditionalGet lets clients revalidate the responses of a GET handler with If-None-Match or If-Modified-Since
+func ConditionalGet(next buffalo.Handler) buffalo.Handler {
+	return func(c buffalo.Context) error {
+		if c.Request().Method != http.MethodGet {
+			return next(c)
+		}
+		return next(&conditionalContext{Context: c})
+	}
+}
+
+func (c *conditionalContext) Render(status int, rr render.Renderer) error {
+	if status != http.StatusOK || rr == nil {
+		return c.Context.Render(status, rr)
+	}
+
+	data := c.Data()
+	body := &bytes.Buffer{}
+	if err := rr.Render(body, data); err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(body.Bytes())
+	etag := `"` + hex.EncodeToString(hash[:16]) + `"`
+	header := c.Response().Header()
+	header.Set("ETag", etag)
+	lastModified, _ := data[lastModifiedKey].(time.Time)
+	if !lastModified.IsZero() {
+		header.Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	if p, ok := data["pagination"].(paginable); ok {
+		header.Set("X-Pagination", p.Paginate())
+	}
+
+	if notModified(c.Request(), etag, lastModified) {
+		c.Response().WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	header.Set("Content-Type", rr.ContentType())
+	c.Response().WriteHeader(status)
+	_, err := c.Response().Write(body.Bytes())
+	return err
+}
+
+// Checks the validators of a request, If-Modified-Since is ignored when If-None-Match is sent
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := req.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	// Last-Modified only has a precision of seconds
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// Returns when the tables last changed. The stamps are kept by the table_modifications triggers,
+// so that deleting rows changes them too. The table names must not come from the request.
+func tablesLastModified(tx *pop.Connection, tables ...string) (time.Time, error) {
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, table := range tables {
+		placeholders = append(placeholders, "?")
+		args = append(args, table)
+	}
+	result := struct {
+		LastModified nulls.Time `db:"last_modified"`
+	}{}
+	err := tx.RawQuery("select max(modified_at) as last_modified from table_modifications where table_name in ("+strings.Join(placeholders, ", ")+")", args...).First(&result)
+	return result.LastModified.Time, err
+}
@@ -0,0 +1,40 @@
+package actions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func (as *ActionSuite) Test_NotModified() {
+	lastModified := time.Date(2019, 5, 4, 10, 20, 30, 500, time.UTC)
+	etag := `"abc"`
+
+	tests := []struct {
+		headers  map[string]string
+		expected bool
+	}{
+		{map[string]string{}, false},
+		{map[string]string{"If-None-Match": `"abc"`}, true},
+		{map[string]string{"If-None-Match": `"xyz", W/"abc"`}, true},
+		{map[string]string{"If-None-Match": "*"}, true},
+		{map[string]string{"If-None-Match": `"xyz"`}, false},
+		{map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, true},
+		{map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, false},
+		{map[string]string{"If-Modified-Since": "yesterday"}, false},
+		// If-None-Match takes precedence over If-Modified-Since
+		{map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)}, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/issues", nil)
+		for key, value := range tt.headers {
+			req.Header.Set(key, value)
+		}
+		as.Equal(tt.expected, notModified(req, etag, lastModified), tt.headers)
+	}
+
+	req := httptest.NewRequest("GET", "/issues", nil)
+	req.Header.Set("If-Modified-Since", lastModified.Format(http.TimeFormat))
+	as.False(notModified(req, etag, time.Time{}))
+}
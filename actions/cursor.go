@@ -0,0 +1,148 @@
+package actions
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+// issueCursor points at an issue of a listing sorted by (github_updated_at, id)
+type issueCursor struct {
+	UpdatedAt time.Time
+	ID        uuid.UUID
+	// Before pages towards the more recently updated issues
+	Before bool
+}
+
+// issuePageRequest identifies a page of issues either by its number or by a cursor
+type issuePageRequest struct {
+	page    int
+	perPage int
+	cursor  *issueCursor
+}
+
+// Encodes the cursor into an opaque url safe string
+func (c issueCursor) encode() string {
+	direction := "a"
+	if c.Before {
+		direction = "b"
+	}
+	raw := direction + "|" + c.UpdatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decodes a cursor of the "cursor" param
+func decodeIssueCursor(value string) (*issueCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	tmp := strings.Split(string(raw), "|")
+	if len(tmp) != 3 || (tmp[0] != "a" && tmp[0] != "b") {
+		return nil, errors.New("invalid cursor")
+	}
+	updatedAt, err := time.Parse(time.RFC3339Nano, tmp[1])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	id, err := uuid.FromString(tmp[2])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &issueCursor{UpdatedAt: updatedAt, ID: id, Before: tmp[0] == "b"}, nil
+}
+
+// apply limits a query to the issues after (or before) the cursor
+func (c *issueCursor) apply(q *pop.Query) *pop.Query {
+	if c.Before {
+		return q.Where("(issues.github_updated_at, issues.id) > (?, ?)", c.UpdatedAt, c.ID).
+			Order("issues.github_updated_at asc, issues.id asc")
+	}
+	return q.Where("(issues.github_updated_at, issues.id) < (?, ?)", c.UpdatedAt, c.ID).
+		Order("issues.github_updated_at desc, issues.id desc")
+}
+
+// newIssuePageRequest reads the "cursor", "page" and "per_page" params, a cursor takes precedence over the page
+func newIssuePageRequest(params buffalo.ParamValues) (*issuePageRequest, error) {
+	paginator := pop.NewPaginatorFromParams(params)
+	request := &issuePageRequest{page: paginator.Page, perPage: paginator.PerPage}
+	if value := strings.TrimSpace(params.Get("cursor")); value != "" {
+		cursor, err := decodeIssueCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		request.cursor = cursor
+	}
+	return request, nil
+}
+
+// key returns the sort and pagination part of the cache key of the page
+func (p *issuePageRequest) key(filter *issueFilter) map[string]string {
+	key := map[string]string{
+		"sort":     filter.sort,
+		"per_page": strconv.Itoa(p.perPage),
+	}
+	if p.cursor != nil {
+		key["cursor"] = p.cursor.encode()
+	} else {
+		key["page"] = strconv.Itoa(p.page)
+	}
+	return key
+}
+
+// load loads the issues of the page and counts the issues of the filter
+func (p *issuePageRequest) load(tx *pop.Connection, filter *issueFilter, issues *models.Issues) (*pop.Paginator, error) {
+	if p.cursor == nil {
+		q := tx.Paginate(p.page, p.perPage).Eager()
+		return q.Paginator, filter.order(filter.apply(q)).All(issues)
+	}
+
+	if err := p.cursor.apply(filter.apply(tx.Q().Eager())).Limit(p.perPage).All(issues); err != nil {
+		return nil, err
+	}
+	// Pages before the cursor are loaded in ascending order
+	if p.cursor.Before {
+		for i, j := 0, len(*issues)-1; i < j; i, j = i+1, j-1 {
+			(*issues)[i], (*issues)[j] = (*issues)[j], (*issues)[i]
+		}
+	}
+
+	// Cursor pages don't have a number, but the totals are still useful
+	total, err := filter.apply(tx.Q()).Count(&models.Issues{})
+	if err != nil {
+		return nil, err
+	}
+	paginator := &pop.Paginator{PerPage: p.perPage, TotalEntriesSize: total, CurrentEntriesSize: len(*issues)}
+	paginator.TotalPages = (total + p.perPage - 1) / p.perPage
+	return paginator, nil
+}
+
+// cursors returns the cursors of the pages around the loaded issues, they are nil when there isn't such a page.
+// Only the listings sorted by update time can be paged with cursors.
+func (p *issuePageRequest) cursors(filter *issueFilter, issues models.Issues) (next, prev *issueCursor) {
+	if filter.sort != defaultIssueSort || len(issues) == 0 {
+		return nil, nil
+	}
+	first, last := issues[0], issues[len(issues)-1]
+	full := len(issues) == p.perPage
+	backwards := p.cursor != nil && p.cursor.Before
+
+	// A full page might be followed by more issues in the direction it was loaded in
+	hasNext := backwards || full
+	hasPrev := p.page > 1 || (p.cursor != nil && !backwards) || (backwards && full)
+
+	if hasNext {
+		next = &issueCursor{UpdatedAt: last.GithubUpdatedAt, ID: last.ID}
+	}
+	if hasPrev {
+		prev = &issueCursor{UpdatedAt: first.GithubUpdatedAt, ID: first.ID, Before: true}
+	}
+	return next, prev
+}
@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+)
+
+func (as *ActionSuite) Test_IssueCursor_Encoding() {
+	cursor := issueCursor{
+		UpdatedAt: time.Date(2019, 5, 4, 10, 20, 30, 123456000, time.UTC),
+		ID:        uuid.Must(uuid.FromString("6ba7b810-9dad-11d1-80b4-00c04fd430c8")),
+		Before:    true,
+	}
+
+	decoded, err := decodeIssueCursor(cursor.encode())
+	as.NoError(err)
+	as.True(cursor.UpdatedAt.Equal(decoded.UpdatedAt))
+	as.Equal(cursor.ID, decoded.ID)
+	as.True(decoded.Before)
+
+	for _, invalid := range []string{"not a cursor", "eHxmb298YmFy", cursor.encode() + "x"} {
+		_, err := decodeIssueCursor(invalid)
+		as.Error(err, invalid)
+	}
+}
+
+func (as *ActionSuite) Test_IssuePageRequest() {
+	page, err := newIssuePageRequest(url.Values{"page": {"3"}, "per_page": {"2"}})
+	as.NoError(err)
+	as.Equal(map[string]string{"sort": "updated", "page": "3", "per_page": "2"}, page.key(newIssueFilter(url.Values{})))
+
+	_, err = newIssuePageRequest(url.Values{"cursor": {"garbage"}})
+	as.Error(err)
+
+	cursor := issueCursor{UpdatedAt: time.Now(), ID: uuid.Must(uuid.NewV4())}
+	page, err = newIssuePageRequest(url.Values{"cursor": {cursor.encode()}, "page": {"3"}})
+	as.NoError(err)
+	key := page.key(newIssueFilter(url.Values{}))
+	as.Equal(cursor.encode(), key["cursor"])
+	as.Equal("", key["page"])
+}
+
+func (as *ActionSuite) Test_IssuePageRequest_Cursors() {
+	now := time.Now()
+	issues := models.Issues{
+		{ID: uuid.Must(uuid.NewV4()), GithubUpdatedAt: now},
+		{ID: uuid.Must(uuid.NewV4()), GithubUpdatedAt: now.Add(-time.Hour)},
+	}
+	filter := newIssueFilter(url.Values{})
+
+	// The first full page only has a next page
+	next, prev := (&issuePageRequest{page: 1, perPage: 2}).cursors(filter, issues)
+	as.Equal(issues[1].ID, next.ID)
+	as.False(next.Before)
+	as.True(prev == nil)
+
+	// The last page after a cursor only has a previous page
+	after := &issueCursor{UpdatedAt: now.Add(time.Hour), ID: uuid.Must(uuid.NewV4())}
+	next, prev = (&issuePageRequest{page: 1, perPage: 3, cursor: after}).cursors(filter, issues)
+	as.True(next == nil)
+	as.Equal(issues[0].ID, prev.ID)
+	as.True(prev.Before)
+
+	// The first page before a cursor only has a next page
+	before := &issueCursor{UpdatedAt: now.Add(-2 * time.Hour), ID: uuid.Must(uuid.NewV4()), Before: true}
+	next, prev = (&issuePageRequest{page: 1, perPage: 3, cursor: before}).cursors(filter, issues)
+	as.Equal(issues[1].ID, next.ID)
+	as.True(prev == nil)
+
+	// Listings that aren't sorted by update time can't be paged with cursors
+	next, prev = (&issuePageRequest{page: 2, perPage: 2}).cursors(newIssueFilter(url.Values{"sort": {"comments"}}), issues)
+	as.True(next == nil && prev == nil)
+}
@@ -0,0 +1,277 @@
+package actions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+// issueFilterFields are the issue columns that can be filtered through request params
+var issueFilterFields = []string{"language", "experience_needed", "type", "project_id"}
+
+// issueFilter is the whitelisted set of issue filters of a request
+type issueFilter struct {
+	// values holds the accepted values of every filtered field
+	values map[string][]string
+	// search is the full-text search query of the "q" param
+	search string
+	// sort is the key of the issueSort of the "sort" param
+	sort string
+}
+
+// issueSort is an ordering of the issue listings that can be requested with the "sort" param
+type issueSort struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+	// SearchOnly sorts are only available when searching
+	SearchOnly bool `json:"search_only"`
+	orderBy    string
+}
+
+const (
+	defaultIssueSort  = "updated"
+	defaultSearchSort = "relevance"
+)
+
+// issueSorts are the whitelisted sorts, ties are always broken by the issue id
+var issueSorts = []issueSort{
+	{Key: "relevance", Description: "Best match of the search query first", SearchOnly: true, orderBy: "ts_rank(issues.search_vector, search_query) desc, issues.github_updated_at desc"},
+	{Key: "updated", Description: "Recently updated first", orderBy: "issues.github_updated_at desc"},
+	{Key: "newest", Description: "Newest first", orderBy: "issues.github_created_at desc nulls last"},
+	{Key: "oldest", Description: "Oldest first", orderBy: "issues.github_created_at asc nulls last"},
+	{Key: "comments", Description: "Most commented first", orderBy: "issues.comments_count desc, issues.github_updated_at desc"},
+	{Key: "reactions", Description: "Most reacted first", orderBy: "issues.reactions_count desc, issues.github_updated_at desc"},
+	{Key: "project", Description: "Project name", orderBy: "lower(projects.display_name) asc, issues.github_updated_at desc"},
+}
+
+// Finds a whitelisted sort by its key
+func findIssueSort(key string) (issueSort, bool) {
+	for _, s := range issueSorts {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return issueSort{}, false
+}
+
+// searchConfig is the text search configuration the search vectors are built with
+const searchConfig = "english"
+
+// newIssueFilter builds a filter from request params, unknown params are ignored
+func newIssueFilter(params buffalo.ParamValues) *issueFilter {
+	f := &issueFilter{values: map[string][]string{}}
+	for _, field := range issueFilterFields {
+		values := parseFilterValues(params.Get(field))
+		if field == "project_id" {
+			values = resolveProjectIDs(values)
+		}
+		if len(values) > 0 {
+			f.values[field] = values
+		}
+	}
+	f.search = strings.Join(strings.Fields(params.Get("q")), " ")
+
+	// Unknown sorts fall back to the default one instead of failing the request
+	f.sort = defaultIssueSort
+	if f.search != "" {
+		f.sort = defaultSearchSort
+	}
+	if s, exists := findIssueSort(strings.ToLower(strings.TrimSpace(params.Get("sort")))); exists && (!s.SearchOnly || f.search != "") {
+		f.sort = s.Key
+	}
+	return f
+}
+
+// Parses a param value like `go,rust`, `["go","rust"]` or `*`.
+// Empty and `undefined` values are dropped and `*` means no filter.
+func parseFilterValues(param string) []string {
+	param = strings.TrimSpace(param)
+	if param == "" {
+		return nil
+	}
+
+	rawValues := []string{}
+	if !strings.HasPrefix(param, "[") || json.Unmarshal([]byte(param), &rawValues) != nil {
+		rawValues = strings.Split(strings.Trim(param, "[]"), ",")
+	}
+
+	seen := map[string]bool{}
+	var values []string
+	for _, value := range rawValues {
+		value = strings.ToLower(strings.TrimSpace(strings.Trim(strings.TrimSpace(value), `"'`)))
+		switch value {
+		case "", "undefined", "null":
+			continue
+		case "*":
+			return nil
+		}
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// projectIDsBySlugs maps project slugs to their ids, tests replace it to run without a database
+var projectIDsBySlugs = func(slugs []string) (map[string]uuid.UUID, error) {
+	return models.ProjectIDsBySlugs(models.DB, slugs)
+}
+
+// Replaces the project slugs with their ids and drops the values that are neither uuids nor slugs.
+// Unknown slugs become the nil uuid so they match no row, like unknown ids.
+func resolveProjectIDs(values []string) []string {
+	ids := []string{}
+	slugs := []string{}
+	for _, value := range values {
+		if _, err := uuid.FromString(value); err == nil {
+			ids = append(ids, value)
+		} else if models.ValidSlug(value) {
+			slugs = append(slugs, value)
+		}
+	}
+	if len(slugs) == 0 {
+		return ids
+	}
+
+	slugIDs, err := projectIDsBySlugs(slugs)
+	if err != nil {
+		fmt.Println(errors.WithMessage(err, "Project slugs lookup failed"))
+	}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	for _, slug := range slugs {
+		id := slugIDs[slug].String()
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// where returns the where clause of the filter and its bind values
+func (f *issueFilter) where() (string, []interface{}) {
+	return f.whereExcluding("")
+}
+
+// whereExcluding returns the where clause of the filter without the filter of one field
+func (f *issueFilter) whereExcluding(excluded string) (string, []interface{}) {
+	clauses, args := f.conditions(excluded)
+	return strings.Join(append([]string{"issues.closed = false"}, clauses...), " and "), args
+}
+
+// conditions returns the conditions of the filter, open and closed issues alike, without the filter of one field
+func (f *issueFilter) conditions(excluded string) ([]string, []interface{}) {
+	clauses := []string{}
+	args := []interface{}{}
+	for _, field := range issueFilterFields {
+		if field == excluded {
+			continue
+		}
+		values, exists := f.values[field]
+		if !exists {
+			continue
+		}
+		clauses = append(clauses, "issues."+field+" in (?"+strings.Repeat(", ?", len(values)-1)+")")
+		for _, value := range values {
+			args = append(args, value)
+		}
+	}
+	if f.search != "" {
+		clauses = append(clauses, "issues.search_vector @@ websearch_to_tsquery('"+searchConfig+"', ?)")
+		args = append(args, f.search)
+	}
+	return clauses, args
+}
+
+// lastModified returns when an issue matching the filter last changed, closing an issue changes it too
+func (f *issueFilter) lastModified(tx *pop.Connection) (time.Time, error) {
+	clauses, args := f.conditions("")
+	query := "select max(greatest(issues.updated_at, issues.github_updated_at)) as last_modified from issues"
+	if len(clauses) > 0 {
+		query += " where " + strings.Join(clauses, " and ")
+	}
+	result := struct {
+		LastModified nulls.Time `db:"last_modified"`
+	}{}
+	if err := tx.RawQuery(query, args...).First(&result); err != nil {
+		return time.Time{}, err
+	}
+	return result.LastModified.Time, nil
+}
+
+// apply adds the filter to a query
+func (f *issueFilter) apply(q *pop.Query) *pop.Query {
+	clause, args := f.where()
+	return q.Where(clause, args...)
+}
+
+// order sorts a query by the requested sort
+func (f *issueFilter) order(q *pop.Query) *pop.Query {
+	issueSort, _ := findIssueSort(f.sort)
+	switch f.sort {
+	case "relevance":
+		// Joined so that the search query is bound instead of formatted into the order clause
+		q = q.Join("websearch_to_tsquery('"+searchConfig+"', ?) search_query", "true", f.search)
+	case "project":
+		q = q.Join("projects", "projects.id = issues.project_id")
+	}
+	return q.Order(issueSort.orderBy + ", issues.id desc")
+}
+
+// cacheTags returns the tags of the cached results of the filter.
+// Results limited to some projects are only invalidated when the issues of those projects change.
+func (f *issueFilter) cacheTags() []string {
+	projectIDs, exists := f.values["project_id"]
+	if !exists {
+		return []string{cache.IssuesTag}
+	}
+	tags := []string{}
+	for _, projectID := range projectIDs {
+		tags = append(tags, cache.ProjectTag(projectID))
+	}
+	return tags
+}
+
+// cacheKey returns a key that is identical for every request with the same filters and extra params
+func (f *issueFilter) cacheKey(prefix string, extra map[string]string) string {
+	parts := []string{}
+	for _, field := range issueFilterFields {
+		if values, exists := f.values[field]; exists {
+			// Encoded as json so that values with commas can't collide
+			encoded, _ := json.Marshal(values)
+			parts = append(parts, field+"="+string(encoded))
+		}
+	}
+	if f.search != "" {
+		parts = append(parts, "q="+strings.ToLower(f.search))
+	}
+	extraKeys := []string{}
+	for key := range extra {
+		extraKeys = append(extraKeys, key)
+	}
+	sort.Strings(extraKeys)
+	for _, key := range extraKeys {
+		parts = append(parts, "@"+key+"="+extra[key])
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(parts, ";")))
+	return prefix + ":" + hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,152 @@
+package actions
+
+import (
+	"net/url"
+
+	"github.com/gofrs/uuid"
+)
+
+func (as *ActionSuite) Test_ParseFilterValues() {
+	tests := []struct {
+		param    string
+		expected []string
+	}{
+		{"", nil},
+		{"*", nil},
+		{"undefined", nil},
+		{"go", []string{"go"}},
+		{"Rust, go ,go", []string{"go", "rust"}},
+		{`["JavaScript","go"]`, []string{"go", "javascript"}},
+		{`["go","*"]`, nil},
+		{`["undefined"]`, nil},
+		{`"go","undefined"`, []string{"go"}},
+		{"go') or 1=1 --", []string{"go') or 1=1 --"}},
+	}
+
+	for _, tt := range tests {
+		as.Equal(tt.expected, parseFilterValues(tt.param), tt.param)
+	}
+}
+
+func (as *ActionSuite) Test_IssueFilter_Where() {
+	tests := []struct {
+		params   url.Values
+		clause   string
+		args     []interface{}
+		expected string
+	}{
+		{
+			params: url.Values{},
+			clause: "issues.closed = false",
+			args:   []interface{}{},
+		},
+		{
+			params: url.Values{"language": {"go,rust"}, "type": {"*"}, "ordering": {"undefined"}},
+			clause: "issues.closed = false and issues.language in (?, ?)",
+			args:   []interface{}{"go", "rust"},
+		},
+		{
+			params: url.Values{"experience_needed": {`["easy"]`}, "language": {"go' or '1'='1"}},
+			clause: "issues.closed = false and issues.language in (?) and issues.experience_needed in (?)",
+			args:   []interface{}{"go' or '1'='1", "easy"},
+		},
+		{
+			params: url.Values{"project_id": {"6ba7b810-9dad-11d1-80b4-00c04fd430c8,' or 1=1"}},
+			clause: "issues.closed = false and issues.project_id in (?)",
+			args:   []interface{}{"6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		},
+	}
+
+	for _, tt := range tests {
+		clause, args := newIssueFilter(tt.params).where()
+		as.Equal(tt.clause, clause)
+		as.Equal(tt.args, args)
+	}
+}
+
+func (as *ActionSuite) Test_IssueFilter_CacheKey() {
+	key := newIssueFilter(url.Values{"language": {`["go","rust"]`}}).cacheKey("issues", map[string]string{"page": "1"})
+	sameKey := newIssueFilter(url.Values{"language": {"Rust,go"}, "type": {"undefined"}}).cacheKey("issues", map[string]string{"page": "1"})
+	as.Equal(key, sameKey)
+	as.Contains(key, "issues:")
+
+	otherPage := newIssueFilter(url.Values{"language": {"go,rust"}}).cacheKey("issues", map[string]string{"page": "2"})
+	as.NotEqual(key, otherPage)
+
+	noFilter := newIssueFilter(url.Values{"language": {"*"}}).cacheKey("issues", map[string]string{"page": "1"})
+	as.Equal(newIssueFilter(url.Values{}).cacheKey("issues", map[string]string{"page": "1"}), noFilter)
+	as.NotEqual(key, noFilter)
+}
+
+func (as *ActionSuite) Test_IssueFilter_Search() {
+	filter := newIssueFilter(url.Values{"q": {"  crash   on\tstartup "}, "language": {"rust"}})
+	as.Equal("crash on startup", filter.search)
+
+	clause, args := filter.where()
+	as.Equal("issues.closed = false and issues.language in (?) and issues.search_vector @@ websearch_to_tsquery('english', ?)", clause)
+	as.Equal([]interface{}{"rust", "crash on startup"}, args)
+
+	key := filter.cacheKey("issues", nil)
+	as.Equal(key, newIssueFilter(url.Values{"q": {"Crash on startup"}, "language": {"rust"}}).cacheKey("issues", nil))
+	as.NotEqual(key, newIssueFilter(url.Values{"language": {"rust"}}).cacheKey("issues", nil))
+}
+
+func (as *ActionSuite) Test_IssueFilter_Sort() {
+	tests := []struct {
+		params   url.Values
+		expected string
+	}{
+		{url.Values{}, "updated"},
+		{url.Values{"sort": {"Comments"}}, "comments"},
+		{url.Values{"sort": {"id; drop table issues"}}, "updated"},
+		{url.Values{"sort": {"relevance"}}, "updated"},
+		{url.Values{"q": {"crash"}}, "relevance"},
+		{url.Values{"q": {"crash"}, "sort": {"newest"}}, "newest"},
+	}
+
+	for _, tt := range tests {
+		as.Equal(tt.expected, newIssueFilter(tt.params).sort, tt.params.Encode())
+	}
+
+	for _, s := range issueSorts {
+		as.NotEqual("", s.orderBy, s.Key)
+	}
+}
+
+func (as *ActionSuite) Test_IssueFilter_WhereExcluding() {
+	filter := newIssueFilter(url.Values{"language": {"go"}, "type": {"bugfix"}, "q": {"crash"}})
+
+	clause, args := filter.whereExcluding("language")
+	as.Equal("issues.closed = false and issues.type in (?) and issues.search_vector @@ websearch_to_tsquery('english', ?)", clause)
+	as.Equal([]interface{}{"bugfix", "crash"}, args)
+
+	clause, args = filter.whereExcluding("experience_needed")
+	fullClause, fullArgs := filter.where()
+	as.Equal(fullClause, clause)
+	as.Equal(fullArgs, args)
+}
+
+func (as *ActionSuite) Test_IssueFilter_CacheTags() {
+	as.Equal([]string{"issues"}, newIssueFilter(url.Values{"language": {"go"}}).cacheTags())
+
+	projectIDs := "6ba7b810-9dad-11d1-80b4-00c04fd430c8,6ba7b811-9dad-11d1-80b4-00c04fd430c8"
+	as.Equal([]string{
+		"project:6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"project:6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+	}, newIssueFilter(url.Values{"project_id": {projectIDs}}).cacheTags())
+}
+
+func (as *ActionSuite) Test_IssueFilter_ProjectSlugs() {
+	projectID := uuid.Must(uuid.FromString("6ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+	defer func(lookup func([]string) (map[string]uuid.UUID, error)) { projectIDsBySlugs = lookup }(projectIDsBySlugs)
+	projectIDsBySlugs = func(slugs []string) (map[string]uuid.UUID, error) {
+		return map[string]uuid.UUID{"fixme": projectID}, nil
+	}
+
+	filter := newIssueFilter(url.Values{"project_id": {"Fixme,6ba7b810-9dad-11d1-80b4-00c04fd430c8"}})
+	as.Equal([]string{projectID.String()}, filter.values["project_id"])
+
+	// Unknown slugs match no project instead of dropping the filter
+	filter = newIssueFilter(url.Values{"project_id": {"unknown,' or 1=1"}})
+	as.Equal([]string{uuid.Nil.String()}, filter.values["project_id"])
+}
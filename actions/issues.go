@@ -3,13 +3,16 @@ package actions
 import (
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
 	"github.com/ossn/fixme_backend/cache"
 	"github.com/ossn/fixme_backend/models"
 	"github.com/pkg/errors"
@@ -22,74 +25,105 @@ type IssuesResource struct {
 
 // ListOpen gets all Issues. This function is mapped to the path
 // GET /issues
+// The issues are wrapped in a {data, meta} envelope with the "envelope=true" param.
 func (v IssuesResource) ListOpen(c buffalo.Context) error {
-	// Get the DB connection from the context
-	tx, ok := c.Value("tx").(*pop.Connection)
-	if !ok {
-		return errors.WithStack(errors.New("no transaction found"))
-	}
-
-	// Getting connection form the pool of connections from the cache
-	cacheConn := cache.CachePool.Get()
-	defer cacheConn.Close()
-
-	// Retrieve all Issues from the DB
-	issues := &models.Issues{}
 	params := c.Params()
 
-	// Paginate results. Params "page" and "per_page" control pagination.
+	// Paginate results. Params "cursor" or "page", and "per_page" control pagination.
 	// Default values are "page=1" and "per_page=20".
-	q := tx.PaginateFromParams(params).Eager()
+	page, err := newIssuePageRequest(params)
+	if err != nil {
+		return c.Error(http.StatusBadRequest, err)
+	}
 
-	whereClause := "closed = false"
+	filter := newIssueFilter(params)
+	if page.cursor != nil && filter.sort != defaultIssueSort {
+		return c.Error(http.StatusBadRequest, fmt.Errorf("cursor pagination is only supported with sort=%s", defaultIssueSort))
+	}
+	cacheKey := filter.cacheKey("issues-page", page.key(filter))
 
-	for _, filter := range []string{"language", "experience_needed", "type", "project_id"} {
-		param := params.Get(filter)
-		if param != "" {
-			requestParamToQueryFilter(&whereClause, &param, &filter)
-		}
+	value, err := cache.Fetch(cacheKey, func() (string, error) {
+		return loadIssuesPage(filter, page)
+	}, filter.cacheTags()...)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	page := params.Get("page")
-	cacheKey := "issues:" + whereClause + "and page=" + page
+	cached := &issuesPage{}
+	if err := json.Unmarshal([]byte(value), cached); err != nil {
+		fmt.Println(errors.WithMessage(err, "Json unmarshal operation failed"))
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("There was an error retrieving the issues"))
+	}
+	issues := &cached.Issues
+	paginator := cached.Paginator
+	if paginator == nil {
+		paginator = pop.NewPaginator(page.page, page.perPage)
+	}
 
-	ok, err := cache.Exists(&cacheConn, cacheKey)
+	next, prev := page.cursors(filter, *issues)
+	if next != nil {
+		c.Response().Header().Set("X-Next-Cursor", next.encode())
+	}
+	if prev != nil {
+		c.Response().Header().Set("X-Prev-Cursor", prev.encode())
+	}
 
-	if err == nil && ok {
-		value, err := cache.GetString(&cacheConn, cacheKey)
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache get operation failed"))
-		} else {
-			err = json.Unmarshal([]byte(value), issues)
-			if err != nil {
-				fmt.Println(errors.WithMessage(err, "Cache unmarshal operation failed"))
-				issues = &models.Issues{}
-			}
-		}
+	// Caching issues of next page of the same query, following the cursor when the listing can be paged with one
+	switch {
+	case next != nil:
+		go preCacheIssues(filter, &issuePageRequest{page: 1, perPage: page.perPage, cursor: next})
+	case page.cursor == nil:
+		go preCacheIssues(filter, &issuePageRequest{page: page.page + 1, perPage: page.perPage})
 	}
 
-	if len(*issues) < 1 {
-		//TODO: send error to a logger package which will ignore it if nil
-		if err := q.Where(whereClause).Order("github_updated_at desc").All(issues); err != nil {
-			return errors.WithStack(err)
+	if page.cursor == nil {
+		c.Set("pagination", paginator)
+	}
+	c.Set(lastModifiedKey, issuesLastModified(filter))
+
+	if envelope, _ := strconv.ParseBool(params.Get("envelope")); envelope {
+		meta := issuesPageMeta{
+			Page:         paginator.Page,
+			PerPage:      paginator.PerPage,
+			TotalEntries: paginator.TotalEntriesSize,
+			TotalPages:   paginator.TotalPages,
 		}
-		jsonIssues, err := json.Marshal(issues)
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Json marshal operation failed"))
-			return c.Error(http.StatusInternalServerError, fmt.Errorf("There was an error retrieving the issues"))
+		if next != nil {
+			meta.NextCursor = next.encode()
 		}
-		_, err = cache.SetEx(&cacheConn, cacheKey, 600, string(jsonIssues))
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache set operation failed"))
+		if prev != nil {
+			meta.PrevCursor = prev.encode()
 		}
+		return c.Render(200, r.JSON(map[string]interface{}{"data": issues, "meta": meta}))
 	}
 
-	// Caching issues of next page of the same query
-	go preCacheIssues(whereClause, params, page)
+	return c.Render(200, r.JSON(issues))
+}
 
-	c.Set("pagination", q.Paginator)
+// issuesPage is the cached payload of a page of issues
+type issuesPage struct {
+	Issues    models.Issues  `json:"issues"`
+	Paginator *pop.Paginator `json:"paginator"`
+}
 
-	return c.Render(200, r.JSON(issues))
+// issuesPageMeta is the pagination of a page of issues in an envelope, the page is omitted for cursor pages
+type issuesPageMeta struct {
+	Page         int    `json:"page,omitempty"`
+	PerPage      int    `json:"per_page"`
+	TotalEntries int    `json:"total_entries"`
+	TotalPages   int    `json:"total_pages"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+}
+
+// Sorts lists the sorts the issues listing accepts. This function is mapped to the path
+// GET /issues/sorts
+func (v IssuesResource) Sorts(c buffalo.Context) error {
+	return c.Render(200, r.JSON(map[string]interface{}{
+		"default":        defaultIssueSort,
+		"default_search": defaultSearchSort,
+		"sorts":          issueSorts,
+	}))
 }
 
 // List gets all Issues. This function is mapped to the path
@@ -139,109 +173,79 @@ func (v IssuesResource) Show(c buffalo.Context) error {
 // Count counts all Issues. This function is mapped to the path
 // GET /issues-count
 func (v IssuesResource) Count(c buffalo.Context) error {
-	// Get the DB connection from the context
-	q, ok := c.Value("tx").(*pop.Connection)
-	if !ok {
-		return errors.WithStack(errors.New("no transaction found"))
-	}
-
-	var count int
-	//Getting connection form the pool of connections from the cache
-	cacheConn := cache.CachePool.Get()
-	defer cacheConn.Close()
+	filter := newIssueFilter(c.Params())
+	cacheKey := filter.cacheKey("issues-count", nil)
 
-	issues := &models.Issues{}
-	params := c.Params()
-	// Paginate results. Params "page" and "per_page" control pagination.
-	// Default values are "page=1" and "per_page=20".
-
-	whereClause := "closed = false"
-	for _, filter := range []string{"language", "experience_needed", "type", "project_id"} {
-		param := params.Get(filter)
-		if param != "" {
-			requestParamToQueryFilter(&whereClause, &param, &filter)
-		}
+	value, err := cache.Fetch(cacheKey, func() (string, error) {
+		// Count Issues from the DB
+		count, err := filter.apply(models.DB.Q()).Count(&models.Issues{})
+		return strconv.Itoa(count), err
+	}, filter.cacheTags()...)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	page := params.Get("page")
-	cacheKey := "issues-count:" + whereClause + "and page=" + page
-	ok, err := cache.Exists(&cacheConn, cacheKey)
-	if err != nil || !ok {
-		count, err = q.Where(whereClause).Count(issues)
-		// Count Issues from the DB
-		if err != nil {
-			return errors.WithStack(err)
-		}
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set(lastModifiedKey, issuesLastModified(filter))
+	return c.Render(200, r.JSON(count))
+}
 
-		_, err = cache.SetEx(&cacheConn, cacheKey, 600, count)
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache operation failed"))
-		}
-	} else {
-		count, err = cache.GetInt(&cacheConn, cacheKey)
+// facetValue is the number of open issues with a value of a filtered field
+type facetValue struct {
+	Value string `json:"value" db:"value"`
+	Count int    `json:"count" db:"count"`
+}
 
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache operation failed"))
-			count, err = q.Where(whereClause).Count(issues)
-			// Count Issues from the DB
+// Facets counts the open issues per value of every filtered field. This function is mapped to the path
+// GET /issues/facets
+// The counts of a field ignore its own filter so that the other values can still be selected.
+func (v IssuesResource) Facets(c buffalo.Context) error {
+	filter := newIssueFilter(c.Params())
+	cacheKey := filter.cacheKey("issues-facets", nil)
+
+	// The counts of the project facet include the other projects, so they are invalidated by every change
+	value, err := cache.Fetch(cacheKey, func() (string, error) {
+		facets := map[string][]facetValue{}
+		for _, field := range issueFilterFields {
+			values, err := countFacet(models.DB, filter, field)
 			if err != nil {
-				return errors.WithStack(err)
+				return "", err
 			}
+			facets[field] = values
 		}
+		jsonFacets, err := json.Marshal(facets)
+		return string(jsonFacets), err
+	}, cache.IssuesTag)
+	if err != nil {
+		return errors.WithStack(err)
 	}
 
-	return c.Render(200, r.JSON(count))
-}
-
-// Update a query to include
-func requestParamToQueryFilter(query, paramValue, paramName *string) {
-	initialWhereClause := *query
-	if *paramValue != "" {
-		*paramValue = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(*paramValue), "[\""), "\"]")
-		splitParam := strings.Split(*paramValue, ",")
-		for i := range splitParam {
-			splitParam[i] = strings.Trim(splitParam[i], "\"")
-
-			switch splitParam[i] {
-			case "", "undefined":
-				splitParam = append(splitParam[:i], splitParam[i+1:]...)
-			case "*":
-				*query = initialWhereClause
-				return
-			}
-		}
-		if len(splitParam) > 0 {
-			*query += " and " + *paramName + " in ("
-			for i, t := range splitParam {
-				if i > 0 {
-					*query += ","
-				}
-				*query += "'" + strings.TrimSpace(t) + "'"
-			}
-			*query += ")"
-		}
+	facets := map[string][]facetValue{}
+	if err := json.Unmarshal([]byte(value), &facets); err != nil {
+		fmt.Println(errors.WithMessage(err, "Json unmarshal operation failed"))
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("There was an error counting the issues"))
 	}
+	return c.Render(200, r.JSON(facets))
 }
 
-func preCacheIssues(whereClause string, params buffalo.ParamValues, page string) {
-	cacheConn := cache.CachePool.Get()
-	defer cacheConn.Close()
-
-	issues := &models.Issues{}
-	nextPage, err := strconv.Atoi(page)
-	if err != nil {
-		nextPage = 1
-		fmt.Println(errors.WithMessage(err, "preCacheIssues: Failed to parse page"))
-	}
-	nextPage++
-	nextPageStr := strconv.Itoa(nextPage)
-	nextParams := params.(url.Values)
-	nextParams.Set("page", nextPageStr)
-	nextQ := models.DB.PaginateFromParams(nextParams).Eager()
+// Counts the open issues matching the filter per value of a field, the most common values first
+func countFacet(tx *pop.Connection, filter *issueFilter, field string) ([]facetValue, error) {
+	clause, args := filter.whereExcluding(field)
+	values := []facetValue{}
+	// field is one of issueFilterFields so it's safe to format it into the query
+	err := tx.RawQuery("select issues."+field+"::text as value, count(*) as count from issues where "+clause+
+		" and issues."+field+" is not null group by issues."+field+" order by count desc, value asc", args...).All(&values)
+	return values, err
+}
 
-	nextCacheKey := "issues:" + whereClause + "and page=" + nextPageStr
+// Caches a page of issues if it isn't cached already
+func preCacheIssues(filter *issueFilter, page *issuePageRequest) {
+	nextCacheKey := filter.cacheKey("issues-page", page.key(filter))
 
-	ok, err := cache.Exists(&cacheConn, nextCacheKey)
+	ok, err := cache.Backend.Exists(nextCacheKey)
 	if err != nil {
 		fmt.Println(errors.WithMessage(err, "preCacheIssues: Cache operation failed"))
 		return
@@ -251,18 +255,100 @@ func preCacheIssues(whereClause string, params buffalo.ParamValues, page string)
 		return
 	}
 
-	if err := nextQ.Where(whereClause).All(issues); err != nil {
+	_, err = cache.Fetch(nextCacheKey, func() (string, error) {
+		return loadIssuesPage(filter, page)
+	}, filter.cacheTags()...)
+	if err != nil {
 		fmt.Println(errors.WithMessage(err, "preCacheIssues: DB Operation falied"))
-		return
 	}
+}
 
-	jsonIssues, err := json.Marshal(issues)
+// Loads a page of issues along with its paginator as json.
+// Pages can be loaded after the request is over, so they don't use its transaction.
+func loadIssuesPage(filter *issueFilter, page *issuePageRequest) (string, error) {
+	loaded := &issuesPage{}
+	paginator, err := page.load(models.DB, filter, &loaded.Issues)
 	if err != nil {
-		fmt.Println(errors.WithMessage(err, "preCacheIssues: Cache marshal operation failed"))
-		return
+		return "", err
+	}
+	loaded.Paginator = paginator
+	if err := addSearchHighlights(models.DB, &loaded.Issues, filter.search); err != nil {
+		return "", err
 	}
-	_, err = cache.SetEx(&cacheConn, nextCacheKey, 600, string(jsonIssues))
+	jsonPage, err := json.Marshal(loaded)
 	if err != nil {
-		fmt.Println(errors.WithMessage(err, "preCacheIssues: Cache operation failed"))
+		return "", errors.WithMessage(err, "Json marshal operation failed")
+	}
+	return string(jsonPage), nil
+}
+
+// Returns when the issues of a filter last changed, a zero time when it's unknown
+func issuesLastModified(filter *issueFilter) time.Time {
+	value, err := cache.Fetch(filter.cacheKey("issues-modified", nil), func() (string, error) {
+		lastModified, err := filter.lastModified(models.DB)
+		return lastModified.UTC().Format(time.RFC3339Nano), err
+	}, filter.cacheTags()...)
+	if err != nil {
+		fmt.Println(errors.WithMessage(err, "failed to find when the issues last changed"))
+		return time.Time{}
+	}
+	lastModified, _ := time.Parse(time.RFC3339Nano, value)
+	return lastModified
+}
+
+// The matches are wrapped in control characters by ts_headline, they are replaced by <mark> tags once the text is escaped
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+var highlightMarks = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+
+// Escapes a highlighted text, so that only the <mark> tags are left as html
+func escapeHighlight(text string) string {
+	return highlightMarks.Replace(html.EscapeString(text))
+}
+
+// Sets the search rank and the highlighted title and body of the issues that matched a search
+func addSearchHighlights(tx *pop.Connection, issues *models.Issues, search string) error {
+	if search == "" || len(*issues) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(*issues))
+	for i, issue := range *issues {
+		ids[i] = issue.ID
+	}
+
+	highlights := []struct {
+		ID    uuid.UUID `db:"id"`
+		Rank  float64   `db:"rank"`
+		Title string    `db:"title"`
+		Body  string    `db:"body"`
+	}{}
+	err := tx.RawQuery(`select id, ts_rank(search_vector, search_query) as rank,
+		ts_headline('`+searchConfig+`', coalesce(title, ''), search_query, ?) as title,
+		ts_headline('`+searchConfig+`', coalesce(body, ''), search_query, ?) as body
+		from issues, websearch_to_tsquery('`+searchConfig+`', ?) search_query where id in (?)`,
+		"HighlightAll=true, StartSel="+highlightStart+", StopSel="+highlightStop,
+		"MaxFragments=2, MaxWords=30, MinWords=10, StartSel="+highlightStart+", StopSel="+highlightStop,
+		search, ids).All(&highlights)
+	if err != nil {
+		return err
 	}
+
+	for _, highlight := range highlights {
+		for i := range *issues {
+			if (*issues)[i].ID == highlight.ID {
+				(*issues)[i].SearchRank = highlight.Rank
+				(*issues)[i].Highlight = &models.Highlight{Title: escapeHighlight(highlight.Title), Body: escapeHighlight(highlight.Body)}
+			}
+		}
+	}
+	return nil
+}
+
+// Caches the default issues of the issues landing page
+func warmIssuesCache() {
+	preCacheIssues(newIssueFilter(url.Values{}), &issuePageRequest{page: 1, perPage: pop.PaginatorPerPageDefault})
 }
@@ -2,11 +2,16 @@ package actions
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/rand"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/suite"
 	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/cache"
 	"github.com/ossn/fixme_backend/models"
 )
 
@@ -57,3 +62,166 @@ func (as *IssuesSuite) Test_IssuesResource_Count() {
 	as.Equal(200, res.Code)
 	as.Equal(randCount, count)
 }
+
+// Creates issues in a new project and repository, the most recently updated first.
+// The cached issue listings are dropped since the issues aren't saved by the worker.
+func (as *ActionSuite) createIssues(issues ...models.Issue) (*models.Project, models.Issues) {
+	project := &models.Project{DisplayName: "Project", Description: "Description", Logo: "logo.png", Link: "https://example.com"}
+	as.NoError(as.DB.Create(project))
+	repository := &models.Repository{RepositoryUrl: "https://github.com/owner/name", ProjectID: project.ID}
+	as.NoError(as.DB.Create(repository))
+
+	now := time.Now().Truncate(time.Second)
+	created := models.Issues{}
+	for i, issue := range issues {
+		issue.GithubID = i + 1
+		issue.Number = i + 1
+		issue.URL = fmt.Sprintf("https://github.com/owner/name/issues/%d", i+1)
+		issue.GithubUpdatedAt = now.Add(-time.Duration(i) * time.Minute)
+		issue.ProjectID = project.ID
+		issue.RepositoryID = repository.ID
+		as.NoError(as.DB.Create(&issue))
+		created = append(created, issue)
+	}
+
+	_, err := cache.Backend.Invalidate(cache.IssuesTag)
+	as.NoError(err)
+	return project, created
+}
+
+func (as *ActionSuite) Test_IssuesResource_Facets() {
+	project, _ := as.createIssues(
+		models.Issue{Title: nulls.NewString("Go bug"), Language: nulls.NewString("go"), Type: nulls.NewString("bugfix"), ExperienceNeeded: nulls.NewString("easy")},
+		models.Issue{Title: nulls.NewString("Go feature"), Language: nulls.NewString("go"), Type: nulls.NewString("feature"), ExperienceNeeded: nulls.NewString("easy")},
+		models.Issue{Title: nulls.NewString("Rust bug"), Language: nulls.NewString("rust"), Type: nulls.NewString("bugfix"), ExperienceNeeded: nulls.NewString("senior")},
+		models.Issue{Title: nulls.NewString("Closed Go bug"), Language: nulls.NewString("go"), Type: nulls.NewString("bugfix"), ExperienceNeeded: nulls.NewString("easy"), Closed: true},
+	)
+
+	res := as.JSON("/api/issues/facets?language=go").Get()
+	as.Equal(200, res.Code)
+
+	facets := map[string][]facetValue{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &facets))
+	// The language facet ignores the language filter, the other ones only count the open go issues
+	as.Equal([]facetValue{{Value: "go", Count: 2}, {Value: "rust", Count: 1}}, facets["language"])
+	as.Equal([]facetValue{{Value: "bugfix", Count: 1}, {Value: "feature", Count: 1}}, facets["type"])
+	as.Equal([]facetValue{{Value: "easy", Count: 2}}, facets["experience_needed"])
+	as.Equal([]facetValue{{Value: project.ID.String(), Count: 2}}, facets["project_id"])
+}
+
+// issuesEnvelope is the body of the issue listings requested with "envelope=true"
+type issuesEnvelope struct {
+	Data models.Issues  `json:"data"`
+	Meta issuesPageMeta `json:"meta"`
+}
+
+func (as *ActionSuite) Test_IssuesResource_ListOpen_Pagination() {
+	_, issues := as.createIssues(
+		models.Issue{Title: nulls.NewString("First issue")},
+		models.Issue{Title: nulls.NewString("Second issue")},
+		models.Issue{Title: nulls.NewString("Third issue")},
+	)
+
+	// The second request is served from the cache
+	first := issuesEnvelope{}
+	for i := 0; i < 2; i++ {
+		res := as.JSON("/api/issues?per_page=2&envelope=true").Get()
+		as.Equal(200, res.Code)
+
+		pagination := map[string]int{}
+		as.NoError(json.Unmarshal([]byte(res.Header().Get("X-Pagination")), &pagination))
+		as.Equal(3, pagination["total_entries_size"])
+		as.Equal(2, pagination["total_pages"])
+
+		first = issuesEnvelope{}
+		as.NoError(json.Unmarshal(res.Body.Bytes(), &first))
+		as.Equal(3, first.Meta.TotalEntries)
+		as.Equal(2, first.Meta.TotalPages)
+		as.Len(first.Data, 2)
+		as.Equal(issues[0].ID, first.Data[0].ID)
+		as.Equal(issues[1].ID, first.Data[1].ID)
+		as.NotEqual("", first.Meta.NextCursor)
+	}
+
+	// The next page starts right after the last issue of the first one
+	res := as.JSON("/api/issues?per_page=2&envelope=true&cursor=" + first.Meta.NextCursor).Get()
+	as.Equal(200, res.Code)
+	second := issuesEnvelope{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &second))
+	as.Len(second.Data, 1)
+	as.Equal(issues[2].ID, second.Data[0].ID)
+	as.Equal("", second.Meta.NextCursor)
+	as.NotEqual("", second.Meta.PrevCursor)
+
+	// And the previous page ends right before it
+	res = as.JSON("/api/issues?per_page=2&envelope=true&cursor=" + second.Meta.PrevCursor).Get()
+	as.Equal(200, res.Code)
+	previous := issuesEnvelope{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &previous))
+	as.Len(previous.Data, 2)
+	as.Equal(issues[0].ID, previous.Data[0].ID)
+	as.Equal(issues[1].ID, previous.Data[1].ID)
+}
+
+func (as *ActionSuite) Test_IssuesResource_ConditionalGet() {
+	as.createIssues(models.Issue{Title: nulls.NewString("Test issue")})
+
+	res := as.JSON("/api/issues-count").Get()
+	as.Equal(200, res.Code)
+	as.Equal("1", strings.TrimSpace(res.Body.String()))
+	etag := res.Header().Get("ETag")
+	lastModified := res.Header().Get("Last-Modified")
+	as.NotEqual("", etag)
+	as.NotEqual("", lastModified)
+
+	req := as.JSON("/api/issues-count")
+	req.Headers["If-None-Match"] = etag
+	res = req.Get()
+	as.Equal(304, res.Code)
+	as.Equal("", res.Body.String())
+	as.Equal(etag, res.Header().Get("ETag"))
+
+	req = as.JSON("/api/issues-count")
+	req.Headers["If-Modified-Since"] = lastModified
+	as.Equal(304, req.Get().Code)
+
+	req = as.JSON("/api/issues-count")
+	req.Headers["If-None-Match"] = `"outdated"`
+	as.Equal(200, req.Get().Code)
+
+	// A new issue changes the count, so the previous validators don't match anymore
+	as.createIssues(models.Issue{Title: nulls.NewString("Another issue")})
+	req = as.JSON("/api/issues-count")
+	req.Headers["If-None-Match"] = etag
+	res = req.Get()
+	as.Equal(200, res.Code)
+	as.Equal("2", strings.TrimSpace(res.Body.String()))
+	as.NotEqual(etag, res.Header().Get("ETag"))
+
+	req = as.JSON("/api/issues-count")
+	req.Headers["If-Modified-Since"] = time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+	as.Equal(200, req.Get().Code)
+}
+
+func (as *ActionSuite) Test_IssuesResource_ListOpen_SearchHighlights() {
+	as.createIssues(models.Issue{
+		Title: nulls.NewString("Crash on <b>startup</b>"),
+		Body:  nulls.NewString("The app crashes <script>alert(1)</script>"),
+	})
+
+	res := as.JSON("/api/issues?q=crash").Get()
+	as.Equal(200, res.Code)
+	issues := models.Issues{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &issues))
+	as.Len(issues, 1)
+
+	// Only the matches are left as html
+	highlight := issues[0].Highlight
+	as.NotNil(highlight)
+	as.Contains(highlight.Title, "<mark>Crash</mark>")
+	as.Contains(highlight.Title, "&lt;b&gt;")
+	as.NotContains(highlight.Title, "<b>")
+	as.Contains(highlight.Body, "<mark>crashes</mark>")
+	as.Contains(highlight.Body, "&lt;script&gt;")
+	as.NotContains(highlight.Body, "<script>")
+}
@@ -0,0 +1,161 @@
+package actions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultLatestIssues is the number of latest issues of a project detail
+	defaultLatestIssues = 10
+	maxLatestIssues     = 50
+	// topLabelsCount is the number of most used labels of a project detail
+	topLabelsCount = 10
+)
+
+// projectRepository is a repository of a project detail
+type projectRepository struct {
+	models.Repository
+	LastParsed time.Time `json:"last_parsed"`
+}
+
+// projectDetail is a project with its repositories and a breakdown of its open issues
+type projectDetail struct {
+	models.Project
+	Repositories       []projectRepository `json:"repositories"`
+	IssuesByExperience []facetValue        `json:"issues_by_experience"`
+	IssuesByType       []facetValue        `json:"issues_by_type"`
+	TopLabels          []facetValue        `json:"top_labels"`
+	LatestIssues       models.Issues       `json:"latest_issues"`
+}
+
+// Detail gets a project with its repositories and a breakdown of its open issues. This function is mapped to the path
+// GET /projects/{project_id}
+// The project can be requested by its id or its slug, old slugs of renamed projects redirect to the current one.
+// The "latest" param sets the number of latest issues, 10 by default.
+func (v ProjectsResource) Detail(c buffalo.Context) error {
+	projectID, err := uuid.FromString(c.Param("project_id"))
+	if err != nil {
+		// Get the DB connection from the context
+		tx, ok := c.Value("tx").(*pop.Connection)
+		if !ok {
+			return errors.WithStack(errors.New("no transaction found"))
+		}
+
+		project := &models.Project{}
+		redirected, err := models.FindProject(tx, c.Param("project_id"), project)
+		if err != nil {
+			return c.Error(http.StatusNotFound, errProjectNotFound)
+		}
+		if redirected {
+			return c.Redirect(http.StatusMovedPermanently, projectURL(c.Request().URL, project.Slug))
+		}
+		projectID = project.ID
+	}
+
+	latest, err := strconv.Atoi(c.Param("latest"))
+	if err != nil || latest < 1 {
+		latest = defaultLatestIssues
+	}
+	if latest > maxLatestIssues {
+		latest = maxLatestIssues
+	}
+
+	cacheKey := "project-detail:" + projectID.String() + ":" + strconv.Itoa(latest)
+	value, err := cache.Fetch(cacheKey, func() (string, error) {
+		detail, err := loadProjectDetail(projectID, latest)
+		if err != nil {
+			return "", err
+		}
+		jsonDetail, err := json.Marshal(detail)
+		return string(jsonDetail), err
+	}, cache.ProjectTag(projectID.String()))
+	if errors.Cause(err) == errProjectNotFound {
+		return c.Error(http.StatusNotFound, err)
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	detail := &projectDetail{}
+	if err := json.Unmarshal([]byte(value), detail); err != nil {
+		fmt.Println(errors.WithMessage(err, "Json unmarshal operation failed"))
+		return c.Error(http.StatusInternalServerError, fmt.Errorf("There was an error retrieving the project"))
+	}
+
+	// The project is saved at the end of every sync of its repositories
+	lastModified := detail.UpdatedAt
+	for _, repository := range detail.Repositories {
+		if repository.UpdatedAt.After(lastModified) {
+			lastModified = repository.UpdatedAt
+		}
+	}
+	c.Set(lastModifiedKey, lastModified)
+
+	return c.Render(200, r.JSON(detail))
+}
+
+// Returns the url of a project detail with another slug, keeping the query
+func projectURL(current *url.URL, slug string) string {
+	target := *current
+	target.Path = path.Join(path.Dir(current.Path), slug)
+	return target.String()
+}
+
+// errProjectNotFound is returned when a project detail is loaded for a missing project
+var errProjectNotFound = errors.New("project not found")
+
+// Loads a project detail, it can be loaded after the request is over so it doesn't use its transaction
+func loadProjectDetail(projectID uuid.UUID, latest int) (*projectDetail, error) {
+	tx := models.DB
+	detail := &projectDetail{
+		Repositories: []projectRepository{},
+		LatestIssues: models.Issues{},
+	}
+	if err := tx.Find(&detail.Project, projectID); err != nil {
+		return nil, errProjectNotFound
+	}
+
+	repositories := models.Repositories{}
+	if err := tx.Where("project_id = ?", projectID).Order("repository_url asc").All(&repositories); err != nil {
+		return nil, err
+	}
+	for _, repository := range repositories {
+		detail.Repositories = append(detail.Repositories, projectRepository{Repository: repository, LastParsed: repository.LastParsed})
+	}
+
+	filter := &issueFilter{values: map[string][]string{"project_id": {projectID.String()}}, sort: defaultIssueSort}
+	var err error
+	if detail.IssuesByExperience, err = countFacet(tx, filter, "experience_needed"); err != nil {
+		return nil, err
+	}
+	if detail.IssuesByType, err = countFacet(tx, filter, "type"); err != nil {
+		return nil, err
+	}
+
+	detail.TopLabels = []facetValue{}
+	err = tx.RawQuery(`select label as value, count(*) as count from issues, unnest(issues.labels) label
+		where issues.project_id = ? and issues.closed = false
+		group by label order by count desc, value asc limit ?`, projectID, topLabelsCount).All(&detail.TopLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	q := tx.Q().Limit(latest)
+	if err := filter.order(filter.apply(q)).All(&detail.LatestIssues); err != nil {
+		return nil, err
+	}
+	return detail, nil
+}
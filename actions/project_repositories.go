@@ -0,0 +1,97 @@
+package actions
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/validate"
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
+)
+
+// projectForm is a project along with the urls of the repositories it tracks.
+// The link of the project is its website, it isn't tracked unless it's one of the repositories.
+type projectForm struct {
+	models.Project
+	// Repositories are left untouched when they are missing from an update
+	Repositories *[]string `json:"repositories"`
+}
+
+// Adds and removes the repositories of a project so that it tracks exactly the given urls.
+// It returns the number of removed repositories, the transaction must be rolled back when there are validation errors.
+func syncProjectRepositories(tx *pop.Connection, project *models.Project, urls []string) (*validate.Errors, int, error) {
+	verrs := validate.NewErrors()
+	wanted := []string{}
+	seen := map[string]bool{}
+	for _, url := range urls {
+		normalized, err := worker.NormalizeRepositoryURL(url)
+		if err != nil {
+			verrs.Add("repositories", err.Error())
+			continue
+		}
+		if !seen[normalized] {
+			seen[normalized] = true
+			wanted = append(wanted, normalized)
+		}
+	}
+	if verrs.HasAny() {
+		return verrs, 0, nil
+	}
+
+	repositories := models.Repositories{}
+	if err := tx.Where("project_id = ?", project.ID).All(&repositories); err != nil {
+		return verrs, 0, err
+	}
+	tracked := map[string]bool{}
+	removed := 0
+	for i := range repositories {
+		normalized, err := worker.NormalizeRepositoryURL(repositories[i].RepositoryUrl)
+		if err != nil {
+			normalized = repositories[i].RepositoryUrl
+		}
+		if seen[normalized] && !tracked[normalized] {
+			tracked[normalized] = true
+			continue
+		}
+		// The issues of the repository are deleted along with it
+		if err := tx.Destroy(&repositories[i]); err != nil {
+			return verrs, removed, err
+		}
+		removed++
+	}
+
+	for _, url := range wanted {
+		if tracked[url] {
+			continue
+		}
+		owner := &models.Repository{}
+		count, err := tx.Where("lower(trim(trailing '/' from repository_url)) = lower(?)", url).Count(owner)
+		if err != nil {
+			return verrs, removed, err
+		}
+		if count > 0 {
+			verrs.Add("repositories", fmt.Sprintf("%s is already tracked by another project", url))
+			continue
+		}
+		repositoryErrors, err := tx.ValidateAndCreate(&models.Repository{RepositoryUrl: url, ProjectID: project.ID})
+		if err != nil {
+			return verrs, removed, err
+		}
+		verrs.Append(repositoryErrors)
+	}
+	return verrs, removed, nil
+}
+
+// Returns the urls of the repositories of a project
+func projectRepositoryURLs(tx *pop.Connection, projectID uuid.UUID) ([]string, error) {
+	repositories := models.Repositories{}
+	if err := tx.Where("project_id = ?", projectID).Order("repository_url asc").All(&repositories); err != nil {
+		return nil, err
+	}
+	urls := []string{}
+	for _, repository := range repositories {
+		urls = append(urls, repository.RepositoryUrl)
+	}
+	return urls, nil
+}
@@ -1,12 +1,10 @@
 package actions
 
 import (
-	"time"
-
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/ossn/fixme_backend/cache"
 	"github.com/ossn/fixme_backend/models"
-	"github.com/ossn/fixme_backend/worker"
 	"github.com/pkg/errors"
 )
 
@@ -38,6 +36,12 @@ func (v ProjectsResource) List(c buffalo.Context) error {
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
 
+	lastModified, err := tablesLastModified(tx, "projects")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set(lastModifiedKey, lastModified)
+
 	return c.Render(200, r.JSON(projects))
 }
 
@@ -53,8 +57,8 @@ func (v ProjectsResource) Show(c buffalo.Context) error {
 	// Allocate an empty Project
 	project := &models.Project{}
 
-	// To find the Project the parameter project_id is used.
-	if err := tx.Find(project, c.Param("project_id")); err != nil {
+	// To find the Project the parameter project_id is used, it can be the id or the slug of the project.
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
 		return c.Error(404, err)
 	}
 
@@ -69,12 +73,13 @@ func (v ProjectsResource) New(c buffalo.Context) error {
 
 // Create adds a Project to the DB. This function is mapped to the
 // path POST /projects
+// The "repositories" field lists the urls of the repositories the project tracks.
 func (v ProjectsResource) Create(c buffalo.Context) error {
 	// Allocate an empty Project
-	project := &models.Project{}
+	form := &projectForm{}
 
 	// Bind project to the html form elements
-	if err := c.Bind(project); err != nil {
+	if err := c.Bind(form); err != nil {
 		return errors.WithStack(err)
 	}
 
@@ -85,7 +90,7 @@ func (v ProjectsResource) Create(c buffalo.Context) error {
 	}
 
 	// Validate the data from the html form
-	verrs, err := tx.ValidateAndCreate(project)
+	verrs, err := tx.ValidateAndCreate(&form.Project)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -94,34 +99,31 @@ func (v ProjectsResource) Create(c buffalo.Context) error {
 		// Make the errors available inside the response
 		c.Set("errors", verrs)
 
-		return c.Render(422, r.JSON(project))
-	}
-
-	// Force worker to update the topics
-	go worker.WorkerInst.UpdateRepositoryTopics()
-
-	repo := models.Repository{RepositoryUrl: project.Link, ProjectID: project.ID}
-	count, err := tx.Where("repository_url=?", project.Link).Count(&repo)
-	if err != nil {
-		return errors.WithStack(err)
+		return c.Render(422, r.JSON(form))
 	}
 
-	if count < 1 {
-		verrs, err = tx.ValidateAndCreate(&repo)
-
-		if err != nil {
+	if form.Repositories != nil {
+		if verrs, _, err = syncProjectRepositories(tx, &form.Project, *form.Repositories); err != nil {
 			return errors.WithStack(err)
 		}
-
 		if verrs.HasAny() {
-			// Make the errors available inside the response
 			c.Set("errors", verrs)
-
-			return c.Render(422, r.JSON(project))
+			return c.Render(422, r.JSON(form))
 		}
 	}
 
-	return c.Render(201, r.JSON(project))
+	urls, err := projectRepositoryURLs(tx, form.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	form.Repositories = &urls
+
+	// Force worker to update the topics
+	if err := models.QueueSyncJobs(tx, models.SyncJobKindTopics, "project_id = ?", form.ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(201, r.JSON(form))
 }
 
 // Edit renders a edit form for a Project. This function is
@@ -136,7 +138,7 @@ func (v ProjectsResource) Edit(c buffalo.Context) error {
 	// Allocate an empty Project
 	project := &models.Project{}
 
-	if err := tx.Find(project, c.Param("project_id")); err != nil {
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
 		return c.Error(404, err)
 	}
 
@@ -145,6 +147,7 @@ func (v ProjectsResource) Edit(c buffalo.Context) error {
 
 // Update changes a Project in the DB. This function is mapped to
 // the path PUT /projects/{project_id}
+// The repositories of the project are replaced by the "repositories" field when it's set.
 func (v ProjectsResource) Update(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -153,20 +156,18 @@ func (v ProjectsResource) Update(c buffalo.Context) error {
 	}
 
 	// Allocate an empty Project
-	project := &models.Project{}
+	form := &projectForm{}
 
-	if err := tx.Find(project, c.Param("project_id")); err != nil {
+	if _, err := models.FindProject(tx, c.Param("project_id"), &form.Project); err != nil {
 		return c.Error(404, err)
 	}
 
-	oldProjectUrl := project.Link
-
 	// Bind Project to the html form elements
-	if err := c.Bind(project); err != nil {
+	if err := c.Bind(form); err != nil {
 		return errors.WithStack(err)
 	}
 
-	verrs, err := tx.ValidateAndUpdate(project)
+	verrs, err := tx.ValidateAndUpdate(&form.Project)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -175,33 +176,40 @@ func (v ProjectsResource) Update(c buffalo.Context) error {
 		// Make the errors available inside the response
 		c.Set("errors", verrs)
 
-		return c.Render(422, r.JSON(project))
+		return c.Render(422, r.JSON(form))
 	}
 
-	// Force worker to update topic list
-	go worker.WorkerInst.UpdateRepositoryTopics()
-
-	if oldProjectUrl != project.Link {
-		repo := models.Repository{}
-		if err = tx.Where("project_id=?", project.ID).Where("repository_url=?", oldProjectUrl).First(&repo); err != nil {
-			// if no repo is found just skip update
-			return errors.Wrap(err, "Failed to find repo")
-		}
-		repo.RepositoryUrl = project.Link
-		repo.LastParsed = time.Unix(0, 0)
-		verrs, err = tx.ValidateAndUpdate(&repo)
-		if err != nil {
+	// The cached project detail holds the name and the slug of the project
+	tags := []string{cache.ProjectTag(form.ID.String())}
+	if form.Repositories != nil {
+		var removed int
+		if verrs, removed, err = syncProjectRepositories(tx, &form.Project, *form.Repositories); err != nil {
 			return errors.WithStack(err)
 		}
-
 		if verrs.HasAny() {
-			// Make the errors available inside the response
 			c.Set("errors", verrs)
-
-			return c.Render(422, r.JSON(project))
+			return c.Render(422, r.JSON(form))
+		}
+		// The issues of the removed repositories are gone from every listing
+		if removed > 0 {
+			tags = append(tags, cache.IssuesTag)
 		}
 	}
-	return c.Render(200, r.JSON(project))
+
+	urls, err := projectRepositoryURLs(tx, form.ID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	form.Repositories = &urls
+
+	// Force worker to update topic list
+	if err := models.QueueSyncJobs(tx, models.SyncJobKindTopics, "project_id = ?", form.ID); err != nil {
+		return errors.WithStack(err)
+	}
+
+	invalidateAfterCommit(c, tags...)
+
+	return c.Render(200, r.JSON(form))
 }
 
 // Destroy deletes a Project from the DB. This function is mapped
@@ -216,8 +224,8 @@ func (v ProjectsResource) Destroy(c buffalo.Context) error {
 	// Allocate an empty Project
 	project := &models.Project{}
 
-	// To find the Project the parameter project_id is used.
-	if err := tx.Find(project, c.Param("project_id")); err != nil {
+	// To find the Project the parameter project_id is used, it can be the id or the slug of the project.
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
 		return c.Error(404, err)
 	}
 
@@ -225,5 +233,8 @@ func (v ProjectsResource) Destroy(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	// The issues of the project are gone from every listing
+	invalidateAfterCommit(c, cache.ProjectTag(project.ID.String()), cache.IssuesTag)
+
 	return c.Render(200, r.JSON(project))
 }
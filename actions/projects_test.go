@@ -1,5 +1,13 @@
 package actions
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/ossn/fixme_backend/models"
+)
+
 func (as *ActionSuite) Test_ProjectsResource_List() {
 	as.Fail("Not Implemented!")
 }
@@ -27,3 +35,168 @@ func (as *ActionSuite) Test_ProjectsResource_Update() {
 func (as *ActionSuite) Test_ProjectsResource_Destroy() {
 	as.Fail("Not Implemented!")
 }
+
+func (as *ActionSuite) Test_ProjectsResource_Detail() {
+	project := &models.Project{DisplayName: "Project", Description: "Description", Logo: "logo.png", Link: "https://example.com"}
+	as.NoError(as.DB.Create(project))
+	repository := &models.Repository{RepositoryUrl: "https://github.com/owner/name", ProjectID: project.ID}
+	as.NoError(as.DB.Create(repository))
+	for _, experience := range []string{"easy", "easy", "moderate"} {
+		as.NoError(as.DB.Create(&models.Issue{
+			Title:            nulls.NewString("Issue"),
+			ExperienceNeeded: nulls.NewString(experience),
+			Labels:           []string{"good first issue"},
+			ProjectID:        project.ID,
+			RepositoryID:     repository.ID,
+		}))
+	}
+
+	res := as.JSON("/api/projects/" + project.ID.String() + "?latest=2").Get()
+	as.Equal(200, res.Code)
+
+	detail := &projectDetail{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), detail))
+	as.Equal(project.ID, detail.ID)
+	as.Len(detail.Repositories, 1)
+	as.Equal([]facetValue{{Value: "easy", Count: 2}, {Value: "moderate", Count: 1}}, detail.IssuesByExperience)
+	as.Equal([]facetValue{{Value: "good first issue", Count: 3}}, detail.TopLabels)
+	as.Len(detail.LatestIssues, 2)
+
+	as.Equal(404, as.JSON("/api/projects/not-a-project").Get().Code)
+}
+
+func (as *ActionSuite) Test_ProjectsResource_Detail_Slug() {
+	project := &models.Project{DisplayName: "Fixme Project", Description: "Description", Logo: "logo.png", Link: "https://example.com"}
+	as.NoError(as.DB.Create(project))
+	as.Equal("fixme-project", project.Slug)
+
+	namesake := &models.Project{DisplayName: "Fixme project!", Description: "Description", Logo: "logo.png", Link: "https://example.org"}
+	as.NoError(as.DB.Create(namesake))
+	as.Equal("fixme-project-2", namesake.Slug)
+
+	res := as.JSON("/api/projects/fixme-project").Get()
+	as.Equal(200, res.Code)
+	detail := &projectDetail{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), detail))
+	as.Equal(project.ID, detail.ID)
+
+	// Renaming the project keeps its old slug as a redirect
+	project.DisplayName = "Fixme"
+	as.NoError(as.DB.Update(project))
+	as.Equal("fixme", project.Slug)
+
+	res = as.JSON("/api/projects/fixme-project?latest=5").Get()
+	as.Equal(301, res.Code)
+	as.Equal("/api/projects/fixme?latest=5", res.Header().Get("Location"))
+	as.Equal(200, as.JSON("/api/projects/fixme").Get().Code)
+
+	// An old slug can't be taken by another project
+	namesake.Slug = "fixme-project"
+	verrs, err := as.DB.ValidateAndUpdate(namesake)
+	as.NoError(err)
+	as.True(verrs.HasAny())
+
+	// An admin can set the slug
+	project.Slug = "fixme-project"
+	verrs, err = as.DB.ValidateAndUpdate(project)
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	as.Equal(200, as.JSON("/api/projects/fixme-project").Get().Code)
+	as.Equal(301, as.JSON("/api/projects/fixme").Get().Code)
+}
+
+func (as *ActionSuite) Test_SyncProjectRepositories() {
+	project := &models.Project{DisplayName: "Common Voice", Description: "Description", Logo: "logo.png", Link: "https://voice.mozilla.org"}
+	as.NoError(as.DB.Create(project))
+	other := &models.Project{DisplayName: "Other", Description: "Description", Logo: "logo.png", Link: "https://example.org"}
+	as.NoError(as.DB.Create(other))
+	as.NoError(as.DB.Create(&models.Repository{RepositoryUrl: "https://github.com/mozilla/other", ProjectID: other.ID}))
+
+	// The website of the project isn't tracked
+	verrs, removed, err := syncProjectRepositories(as.DB, project, []string{
+		"https://github.com/mozilla/voice-web",
+		"https://www.github.com/mozilla/voice-web.git",
+		"https://github.com/mozilla/DeepSpeech/",
+	})
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	as.Equal(0, removed)
+	urls, err := projectRepositoryURLs(as.DB, project.ID)
+	as.NoError(err)
+	as.Equal([]string{"https://github.com/mozilla/DeepSpeech", "https://github.com/mozilla/voice-web"}, urls)
+
+	verrs, removed, err = syncProjectRepositories(as.DB, project, []string{"https://github.com/mozilla/voice-web", "https://gitlab.com/mozilla/voice"})
+	as.NoError(err)
+	as.False(verrs.HasAny())
+	as.Equal(1, removed)
+	urls, err = projectRepositoryURLs(as.DB, project.ID)
+	as.NoError(err)
+	as.Equal([]string{"https://github.com/mozilla/voice-web", "https://gitlab.com/mozilla/voice"}, urls)
+
+	verrs, _, err = syncProjectRepositories(as.DB, project, []string{"https://voice.mozilla.org/en"})
+	as.NoError(err)
+	as.True(verrs.HasAny())
+
+	verrs, _, err = syncProjectRepositories(as.DB, project, []string{"https://github.com/mozilla/other"})
+	as.NoError(err)
+	as.True(verrs.HasAny())
+}
+
+func (as *ActionSuite) Test_BackfillProjectSlugs() {
+	// A project that was created after the slugs, whose slug looks like a collision suffix
+	numbered := &models.Project{DisplayName: "Fixme 2", Description: "Description", Logo: "logo.png", Link: "https://example.com"}
+	as.NoError(as.DB.Create(numbered))
+	as.Equal("fixme-2", numbered.Slug)
+
+	// The projects that existed before the slugs have placeholders
+	legacy := []*models.Project{}
+	for i, name := range []string{"Fixme", "Fixme!", "Café Crème"} {
+		project := &models.Project{DisplayName: name, Description: "Description", Logo: "logo.png", Link: "https://example.org"}
+		as.NoError(as.DB.Create(project))
+		createdAt := time.Now().Add(time.Duration(i-10) * time.Hour)
+		as.NoError(as.DB.RawQuery("update projects set slug = 'project-' || id::text, created_at = ? where id = ?", createdAt, project.ID).Exec())
+		legacy = append(legacy, project)
+	}
+
+	count, err := models.BackfillProjectSlugs(as.DB)
+	as.NoError(err)
+	as.Equal(3, count)
+	for i, expected := range []string{"fixme", "fixme-3", "cafe-creme"} {
+		as.NoError(as.DB.Reload(legacy[i]))
+		as.Equal(expected, legacy[i].Slug)
+	}
+
+	// The placeholders aren't kept as redirects
+	redirects, err := as.DB.Count(&models.ProjectSlug{})
+	as.NoError(err)
+	as.Equal(0, redirects)
+}
+
+func (as *ActionSuite) Test_ProjectsResource_List_LastModified() {
+	project := &models.Project{DisplayName: "Project", Description: "Description", Logo: "logo.png", Link: "https://example.com"}
+	as.NoError(as.DB.Create(project))
+	other := &models.Project{DisplayName: "Other", Description: "Description", Logo: "logo.png", Link: "https://example.org"}
+	as.NoError(as.DB.Create(other))
+	// Last-Modified only has a precision of seconds
+	as.NoError(as.DB.RawQuery("update table_modifications set modified_at = ? where table_name = 'projects'", time.Now().Add(-time.Hour)).Exec())
+
+	res := as.JSON("/api/projects").Get()
+	as.Equal(200, res.Code)
+	lastModified := res.Header().Get("Last-Modified")
+	as.NotEqual("", lastModified)
+
+	req := as.JSON("/api/projects")
+	req.Headers["If-Modified-Since"] = lastModified
+	as.Equal(304, req.Get().Code)
+
+	// Deleting a project doesn't change the updated_at of the others, but the listing is modified anyway
+	as.NoError(as.DB.Destroy(other))
+	req = as.JSON("/api/projects")
+	req.Headers["If-Modified-Since"] = lastModified
+	res = req.Get()
+	as.Equal(200, res.Code)
+	projects := models.Projects{}
+	as.NoError(json.Unmarshal(res.Body.Bytes(), &projects))
+	as.Len(projects, 1)
+	as.Equal(project.ID, projects[0].ID)
+}
@@ -3,7 +3,9 @@ package actions
 import (
 	"github.com/gobuffalo/buffalo"
 	"github.com/gobuffalo/pop"
+	"github.com/ossn/fixme_backend/cache"
 	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
 	"github.com/pkg/errors"
 )
 
@@ -14,6 +16,7 @@ type RepositoriesResource struct {
 
 // List gets all Repositories. This function is mapped to the path
 // GET /repositories
+// The "status" param filters the repositories by status.
 func (v RepositoriesResource) List(c buffalo.Context) error {
 	// Get the DB connection from the context
 	tx, ok := c.Value("tx").(*pop.Connection)
@@ -27,6 +30,11 @@ func (v RepositoriesResource) List(c buffalo.Context) error {
 	// Default values are "page=1" and "per_page=20".
 	q := tx.PaginateFromParams(c.Params())
 
+	// The "status" param lists e.g. the broken repositories
+	if status := c.Param("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+
 	// Retrieve all Repositories from the DB
 	if err := q.Eager("Project").All(repositories); err != nil {
 		return errors.WithStack(err)
@@ -35,6 +43,12 @@ func (v RepositoriesResource) List(c buffalo.Context) error {
 	// Add the paginator to the context so it can be used in the template.
 	c.Set("pagination", q.Paginator)
 
+	lastModified, err := tablesLastModified(tx, "repositories", "projects")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Set(lastModifiedKey, lastModified)
+
 	return c.Render(200, r.JSON(repositories))
 }
 
@@ -94,6 +108,9 @@ func (v RepositoriesResource) Create(c buffalo.Context) error {
 		return c.Render(422, r.JSON(repository))
 	}
 
+	// The project detail lists the repositories of the project
+	invalidateAfterCommit(c, cache.ProjectTag(repository.ProjectID.String()))
+
 	return c.Render(201, r.JSON(repository))
 }
 
@@ -131,6 +148,8 @@ func (v RepositoriesResource) Update(c buffalo.Context) error {
 	if err := tx.Find(repository, c.Param("repository_id")); err != nil {
 		return c.Error(404, err)
 	}
+	// The repository can be moved to another project
+	previousProjectID := repository.ProjectID
 
 	// Bind Repository to the html form elements
 	if err := c.Bind(repository); err != nil {
@@ -149,6 +168,9 @@ func (v RepositoriesResource) Update(c buffalo.Context) error {
 		return c.Render(422, r.JSON(repository))
 	}
 
+	// The listings show the repository of the issues
+	invalidateAfterCommit(c, cache.ProjectTag(previousProjectID.String()), cache.ProjectTag(repository.ProjectID.String()), cache.IssuesTag)
+
 	return c.Render(200, r.JSON(repository))
 }
 
@@ -173,5 +195,39 @@ func (v RepositoriesResource) Destroy(c buffalo.Context) error {
 		return errors.WithStack(err)
 	}
 
+	// The issues of the repository are gone from every listing
+	invalidateAfterCommit(c, cache.ProjectTag(repository.ProjectID.String()), cache.IssuesTag)
+
 	return c.Render(200, r.JSON(repository))
 }
+
+// Sync syncs a repository before the other ones, instead of waiting for its turn. This function is mapped to the path
+// POST /repositories/{repository_id}/sync
+func (v RepositoriesResource) Sync(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	repository := &models.Repository{}
+	if err := tx.Find(repository, c.Param("repository_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	// The job is queued in its own transaction, so that it's saved by the time the worker claims it
+	var job *models.SyncJob
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		var err error
+		job, err = queueRepositorySync(tx, repository)
+		return err
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// The dispatcher picks the job up anyway when the worker runs in another process or is busy with the repository
+	runsNext := worker.WorkerInst.SyncNow(*job)
+
+	return c.Render(202, r.JSON(&repositorySyncResult{Job: job, RunsNext: runsNext}))
+}
@@ -0,0 +1,119 @@
+package actions
+
+import (
+	"net/http"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
+	"github.com/pkg/errors"
+)
+
+// RepositoryImportsResource is the resource for the RepositoryImport model, the imports are nested in their project
+type RepositoryImportsResource struct {
+	buffalo.Resource
+}
+
+// repositoryImportResult is an import rule along with the repositories it created
+type repositoryImportResult struct {
+	Import       *models.RepositoryImport `json:"import"`
+	Repositories models.Repositories      `json:"repositories"`
+}
+
+// List gets the import rules of a project. This function is mapped to the path
+// GET /projects/{project_id}/imports
+func (v RepositoryImportsResource) List(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	project := &models.Project{}
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
+		return c.Error(404, err)
+	}
+
+	rules := &models.RepositoryImports{}
+	if err := tx.Where("project_id = ?", project.ID).Order("owner asc").All(rules); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(rules))
+}
+
+// Create adds an import rule to a project and imports the matching repositories of the
+// github organization or user. This function is mapped to the path
+// POST /projects/{project_id}/imports
+func (v RepositoryImportsResource) Create(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	project := &models.Project{}
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
+		return c.Error(404, err)
+	}
+
+	// Allocate an empty RepositoryImport
+	rule := &models.RepositoryImport{}
+
+	// Bind rule to the html form elements
+	if err := c.Bind(rule); err != nil {
+		return errors.WithStack(err)
+	}
+	rule.ProjectID = project.ID
+
+	verrs, err := tx.ValidateAndCreate(rule)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if verrs.HasAny() {
+		// Make the errors available inside the response
+		c.Set("errors", verrs)
+
+		return c.Render(422, r.JSON(rule))
+	}
+
+	// The rule isn't saved when the repositories can't be listed, so that the admin can fix it
+	repositories, err := worker.WorkerInst.ImportRepositories(tx, rule)
+	if err != nil {
+		return c.Error(http.StatusBadGateway, err)
+	}
+
+	// The project detail lists the repositories of the project
+	invalidateAfterCommit(c, cache.ProjectTag(project.ID.String()))
+
+	return c.Render(201, r.JSON(&repositoryImportResult{Import: rule, Repositories: repositories}))
+}
+
+// Destroy deletes an import rule, the imported repositories are kept. This function is mapped to the path
+// DELETE /projects/{project_id}/imports/{import_id}
+func (v RepositoryImportsResource) Destroy(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	project := &models.Project{}
+	if _, err := models.FindProject(tx, c.Param("project_id"), project); err != nil {
+		return c.Error(404, err)
+	}
+
+	rule := &models.RepositoryImport{}
+	if err := tx.Where("project_id = ?", project.ID).Find(rule, c.Param("import_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := tx.Destroy(rule); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(rule))
+}
@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+// SyncJobsResource is the resource for the SyncJob model
+type SyncJobsResource struct {
+	buffalo.Resource
+}
+
+// List gets the jobs of the worker, the failing ones first. This function is mapped to the path
+// GET /jobs
+// The "status", "kind" and "repository_id" params filter the jobs.
+func (v SyncJobsResource) List(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	jobs := &models.SyncJobs{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.PaginateFromParams(c.Params())
+
+	// The "status" param lists e.g. the dead jobs
+	if status := c.Param("status"); status != "" {
+		q = q.Where("status = ?", status)
+	}
+	if kind := c.Param("kind"); kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if repositoryID := c.Param("repository_id"); repositoryID != "" {
+		q = q.Where("repository_id = ?", repositoryID)
+	}
+
+	if err := q.Order("attempts desc, run_at asc").All(jobs); err != nil {
+		return errors.WithStack(err)
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+
+	return c.Render(200, r.JSON(jobs))
+}
+
+// Retry queues a job to run now with a fresh number of attempts, it's how dead jobs are brought back.
+// This function is mapped to the path POST /jobs/{job_id}/retry
+func (v SyncJobsResource) Retry(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	job := &models.SyncJob{}
+	if err := tx.Find(job, c.Param("job_id")); err != nil {
+		return c.Error(404, err)
+	}
+
+	if err := job.Requeue(tx, time.Now()); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(job))
+}
@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/envy"
+	"github.com/ossn/fixme_backend/worker"
+	"github.com/pkg/errors"
+)
+
+// GithubWebhook receives the GitHub webhook deliveries. This function is mapped to the path
+// POST /webhooks/github
+func GithubWebhook(c buffalo.Context) error {
+	secret := envy.Get("GITHUB_WEBHOOK_SECRET", "")
+	if secret == "" {
+		return c.Error(503, errors.New("github webhooks aren't configured"))
+	}
+
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if !validWebhookSignature(body, c.Request().Header.Get("X-Hub-Signature-256"), secret) {
+		return c.Error(401, errors.New("invalid webhook signature"))
+	}
+
+	eventType := c.Request().Header.Get("X-GitHub-Event")
+	if eventType == "ping" {
+		return c.Render(200, r.JSON(map[string]string{"status": "pong"}))
+	}
+
+	event := &worker.WebhookEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return c.Error(400, errors.WithMessage(err, "invalid webhook payload"))
+	}
+
+	if err := worker.WorkerInst.HandleWebhookEvent(eventType, event); err != nil {
+		fmt.Println(errors.WithMessage(err, "failed to handle webhook"))
+		return c.Error(500, errors.New("failed to handle webhook"))
+	}
+
+	return c.Render(202, r.JSON(map[string]string{"status": "accepted"}))
+}
+
+// Checks a "sha256=<hex hmac>" signature against the payload
+func validWebhookSignature(body []byte, signature, secret string) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,44 @@
+package actions
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+)
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (as *ActionSuite) Test_ValidWebhookSignature() {
+	body := []byte(`{"action":"opened"}`)
+	as.True(validWebhookSignature(body, sign(body, "secret"), "secret"))
+	as.False(validWebhookSignature(body, sign(body, "other"), "secret"))
+	as.False(validWebhookSignature(body, "sha1=abcdef", "secret"))
+	as.False(validWebhookSignature(body, "", "secret"))
+}
+
+func (as *ActionSuite) Test_GithubWebhook_InvalidSignature() {
+	os.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	defer os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+
+	req := as.JSON("/api/webhooks/github")
+	req.Headers["X-GitHub-Event"] = "issues"
+	req.Headers["X-Hub-Signature-256"] = "sha256=00"
+	res := req.Post(map[string]string{"action": "opened"})
+	as.Equal(401, res.Code)
+}
+
+func (as *ActionSuite) Test_GithubWebhook_Ping() {
+	os.Setenv("GITHUB_WEBHOOK_SECRET", "secret")
+	defer os.Unsetenv("GITHUB_WEBHOOK_SECRET")
+
+	req := as.JSON("/api/webhooks/github")
+	req.Headers["X-GitHub-Event"] = "ping"
+	req.Headers["X-Hub-Signature-256"] = sign([]byte(`{"zen":"Keep it logically awesome."}`), "secret")
+	res := req.Post(map[string]string{"zen": "Keep it logically awesome."})
+	as.Equal(200, res.Code)
+}
@@ -0,0 +1,124 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/gobuffalo/buffalo"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
+	"github.com/pkg/errors"
+)
+
+type (
+	// syncJobCount is the number of jobs of a kind in a status
+	syncJobCount struct {
+		Kind   string `json:"kind" db:"kind"`
+		Status string `json:"status" db:"status"`
+		Count  int    `json:"count" db:"count"`
+	}
+
+	// workerStatus is what the worker of this process is doing along with the jobs of every worker
+	workerStatus struct {
+		worker.Status
+		Jobs []syncJobCount `json:"jobs"`
+	}
+
+	// repositorySyncStatus is a repository along with its jobs
+	repositorySyncStatus struct {
+		models.Repository
+		Jobs models.SyncJobs `json:"jobs"`
+	}
+
+	// repositorySyncResult is the sync job of a repository, RunsNext is false when the job waits in the queue
+	repositorySyncResult struct {
+		Job      *models.SyncJob `json:"job"`
+		RunsNext bool            `json:"runs_next"`
+	}
+)
+
+// WorkerStatus returns the running and queued jobs, the number of jobs by status and the quotas of the sources.
+// This function is mapped to the path GET /admin/worker
+func WorkerStatus(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	status := &workerStatus{Status: worker.WorkerInst.Status(), Jobs: []syncJobCount{}}
+	if err := tx.RawQuery("select kind, status, count(*) as count from sync_jobs group by kind, status order by kind, status").All(&status.Jobs); err != nil {
+		return errors.WithStack(err)
+	}
+
+	return c.Render(200, r.JSON(status))
+}
+
+// WorkerRepositories lists the repositories along with the last success and failure of their jobs.
+// This function is mapped to the path GET /admin/worker/repositories
+// The "failing" param only lists the repositories whose jobs failed since their last success.
+func WorkerRepositories(c buffalo.Context) error {
+	// Get the DB connection from the context
+	tx, ok := c.Value("tx").(*pop.Connection)
+	if !ok {
+		return errors.WithStack(errors.New("no transaction found"))
+	}
+
+	repositories := models.Repositories{}
+
+	// Paginate results. Params "page" and "per_page" control pagination.
+	// Default values are "page=1" and "per_page=20".
+	q := tx.PaginateFromParams(c.Params())
+	if c.Param("failing") == "true" {
+		q = q.Where("exists (select 1 from sync_jobs where sync_jobs.repository_id = repositories.id and sync_jobs.attempts > 0)")
+	}
+	if err := q.Order("repository_url asc").All(&repositories); err != nil {
+		return errors.WithStack(err)
+	}
+
+	statuses := make([]repositorySyncStatus, len(repositories))
+	indexes := map[uuid.UUID]int{}
+	ids := []interface{}{}
+	for i, repository := range repositories {
+		statuses[i] = repositorySyncStatus{Repository: repository, Jobs: models.SyncJobs{}}
+		indexes[repository.ID] = i
+		ids = append(ids, repository.ID)
+	}
+	if len(ids) > 0 {
+		jobs := models.SyncJobs{}
+		if err := tx.Where("repository_id in (?)", ids...).Order("kind asc").All(&jobs); err != nil {
+			return errors.WithStack(err)
+		}
+		for _, job := range jobs {
+			i := indexes[job.RepositoryID]
+			statuses[i].Jobs = append(statuses[i].Jobs, job)
+		}
+	}
+
+	// Add the paginator to the context so it can be used in the template.
+	c.Set("pagination", q.Paginator)
+
+	return c.Render(200, r.JSON(statuses))
+}
+
+// Queues the sync job of a repository before all the others, even when it's dead
+func queueRepositorySync(tx *pop.Connection, repository *models.Repository) (*models.SyncJob, error) {
+	// Like the repositories that were never synced, the job goes in front of the queue
+	repository.LastParsed = time.Time{}
+	if err := tx.RawQuery("update repositories set last_parsed = ? where id = ?", repository.LastParsed, repository.ID).Exec(); err != nil {
+		return nil, err
+	}
+	if err := models.QueueSyncJobs(tx, models.SyncJobKindSync, "repositories.id = ?", repository.ID); err != nil {
+		return nil, err
+	}
+
+	job := &models.SyncJob{}
+	if err := tx.Where("repository_id = ? and kind = ?", repository.ID, models.SyncJobKindSync).First(job); err != nil {
+		return nil, err
+	}
+	if err := job.Requeue(tx, repository.LastParsed); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
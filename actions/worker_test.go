@@ -0,0 +1,74 @@
+package actions
+
+import (
+	"time"
+
+	"github.com/ossn/fixme_backend/models"
+)
+
+func (as *ActionSuite) Test_QueueRepositorySync() {
+	project := &models.Project{DisplayName: "Common Voice", Description: "Description", Logo: "logo.png", Link: "https://voice.mozilla.org"}
+	as.NoError(as.DB.Create(project))
+	repository := &models.Repository{RepositoryUrl: "https://github.com/mozilla/voice-web", ProjectID: project.ID}
+	as.NoError(as.DB.Create(repository))
+
+	// The job is created when the repository doesn't have one yet
+	job, err := queueRepositorySync(as.DB, repository)
+	as.NoError(err)
+	as.Equal(models.SyncJobKindSync, job.Kind)
+	as.Equal(models.SyncJobStatusQueued, job.Status)
+
+	// Dead jobs are brought back
+	job.Status = models.SyncJobStatusDead
+	job.Attempts = 5
+	as.NoError(as.DB.Update(job))
+	job, err = queueRepositorySync(as.DB, repository)
+	as.NoError(err)
+	as.Equal(models.SyncJobStatusQueued, job.Status)
+	as.Equal(0, job.Attempts)
+
+	// The job goes before the ones that were due already
+	other := &models.Repository{RepositoryUrl: "https://github.com/mozilla/DeepSpeech", ProjectID: project.ID, LastParsed: time.Now().Add(-time.Hour)}
+	as.NoError(as.DB.Create(other))
+	as.NoError(models.CreateMissingSyncJobs(as.DB, models.SyncJobKindSync))
+	next := &models.SyncJob{}
+	as.NoError(as.DB.Where("kind = ?", models.SyncJobKindSync).Order("run_at asc").First(next))
+	as.Equal(repository.ID, next.RepositoryID)
+
+	count, err := as.DB.Where("repository_id = ?", repository.ID).Count(&models.SyncJob{})
+	as.NoError(err)
+	as.Equal(1, count)
+}
+
+func (as *ActionSuite) Test_SyncJob_Claim() {
+	project := &models.Project{DisplayName: "Common Voice", Description: "Description", Logo: "logo.png", Link: "https://voice.mozilla.org"}
+	as.NoError(as.DB.Create(project))
+	repository := &models.Repository{RepositoryUrl: "https://github.com/mozilla/voice-web", ProjectID: project.ID, LastParsed: time.Now().Add(-time.Hour)}
+	as.NoError(as.DB.Create(repository))
+	as.NoError(models.CreateMissingSyncJobs(as.DB, models.SyncJobKindTopics))
+	job := models.SyncJob{}
+	as.NoError(as.DB.Where("repository_id = ?", repository.ID).First(&job))
+
+	// A stale copy of the job can't be claimed once the job ran
+	stale := job
+	claimed, err := job.Claim(as.DB)
+	as.NoError(err)
+	as.True(claimed)
+	as.Equal(models.SyncJobStatusRunning, job.Status)
+	claimed, err = stale.Claim(as.DB)
+	as.NoError(err)
+	as.False(claimed)
+
+	job.Status = models.SyncJobStatusQueued
+	job.RunAt = time.Now().Add(time.Hour)
+	as.NoError(job.Finish(as.DB))
+	claimed, err = stale.Claim(as.DB)
+	as.NoError(err)
+	as.False(claimed)
+
+	// Only the running jobs are saved by the worker
+	job.Status = models.SyncJobStatusDead
+	as.NoError(job.Finish(as.DB))
+	as.NoError(as.DB.Reload(&job))
+	as.Equal(models.SyncJobStatusQueued, job.Status)
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"fmt"
-	"log"
 	"time"
 
 	"github.com/caarlos0/env"
@@ -10,38 +9,60 @@ import (
 )
 
 type config struct {
-	Server      string        `env:"REDIS_SERVER" envDefault:"127.0.0.1:6379"`
-	MaxIdle     int           `env:"REDIS_MAX_IDLE" envDefault:"10"`
-	MaxActive   int           `env:"REDIS_MAX_ACTIVE" envDefault:"100"`
-	IdleTimeout time.Duration `env:"REDIS_IDLE_TIMEOUT" envDefault:"24s"`
-	Wait        bool          `env:"REDIS_WAIT" envDefault:"true"`
+	Backend        string        `env:"CACHE_BACKEND" envDefault:"redis"`
+	MemorySize     int           `env:"CACHE_MEMORY_SIZE" envDefault:"10000"`
+	SoftTTL        time.Duration `env:"CACHE_SOFT_TTL" envDefault:"10m"`
+	HardTTL        time.Duration `env:"CACHE_HARD_TTL" envDefault:"1h"`
+	Server         string        `env:"REDIS_SERVER" envDefault:"127.0.0.1:6379"`
+	MaxIdle        int           `env:"REDIS_MAX_IDLE" envDefault:"10"`
+	MaxActive      int           `env:"REDIS_MAX_ACTIVE" envDefault:"100"`
+	IdleTimeout    time.Duration `env:"REDIS_IDLE_TIMEOUT" envDefault:"24s"`
+	Wait           bool          `env:"REDIS_WAIT" envDefault:"true"`
+	ConnectTimeout time.Duration `env:"REDIS_CONNECT_TIMEOUT" envDefault:"1s"`
+	Timeout        time.Duration `env:"REDIS_TIMEOUT" envDefault:"2s"`
 }
 
 /*CachePool maintains a pool of connections.The application calls the Get method to get
 a connection from the pool and the connection's Close method to return the
-connection's resources to the pool.*/
+connection's resources to the pool. It's nil unless the redis backend is used.*/
 var CachePool *redis.Pool
 
+// Backend is the store the api caches its responses in, use SetBackend to replace it
+var Backend Store
+
+// fetchConfig holds the ttls of the values fetched from the Backend
+var fetchConfig config
+
 func init() {
 	cfg := config{}
 
 	if err := env.Parse(&cfg); err != nil {
 		fmt.Printf("%+v\n", err)
 	}
-
-	CachePool = &redis.Pool{
-		MaxIdle:     cfg.MaxIdle,
-		IdleTimeout: cfg.IdleTimeout,
-		MaxActive:   cfg.MaxActive,
-		Wait:        cfg.Wait,
-		Dial: func() (redis.Conn, error) {
-			c, err := redis.Dial("tcp", cfg.Server)
-			if err != nil {
-				log.Fatal(err)
-				return nil, err
-			}
-			return c, nil
-		},
+	fetchConfig = cfg
+
+	switch cfg.Backend {
+	case "memory":
+		SetBackend(NewMemoryStore(cfg.MemorySize))
+	default:
+		if cfg.Backend != "redis" {
+			fmt.Printf("cache: unknown backend %q, using redis\n", cfg.Backend)
+		}
+		CachePool = &redis.Pool{
+			MaxIdle:     cfg.MaxIdle,
+			IdleTimeout: cfg.IdleTimeout,
+			MaxActive:   cfg.MaxActive,
+			Wait:        cfg.Wait,
+			// Failing to connect only makes the requests uncached
+			Dial: func() (redis.Conn, error) {
+				return redis.Dial("tcp", cfg.Server,
+					redis.DialConnectTimeout(cfg.ConnectTimeout),
+					redis.DialReadTimeout(cfg.Timeout),
+					redis.DialWriteTimeout(cfg.Timeout),
+				)
+			},
+		}
+		SetBackend(NewRedisStore(CachePool))
 	}
 }
 
@@ -81,44 +102,3 @@ func DeleteAllKeys(RConn *redis.Conn) error {
 func DeleteKey(RConn *redis.Conn, key string) (int, error) {
 	return redis.Int((*RConn).Do("UNLINK", key))
 }
-
-// DeleteKeysByPattern delete all keys matching a pattern
-func DeleteKeysByPattern(RConn *redis.Conn, pattern string) {
-	start := time.Now()
-	var (
-		cursor        int64
-		items         []string
-		noOfKeysToDel int64
-	)
-
-	for {
-		values, err := redis.Values((*RConn).Do("SCAN", cursor, "MATCH", pattern, "COUNT", 100))
-		if err != nil {
-			fmt.Println("cache:", err)
-			return
-		}
-
-		_, err = redis.Scan(values, &cursor, &items)
-		if err != nil {
-			continue
-		}
-
-		for _, item := range items {
-			ttl, _ := redis.Int((*RConn).Do("TTL", item))
-			if time.Now().Add(-time.Duration(600-ttl) * time.Second).Before(start) {
-				err = (*RConn).Send("UNLINK", item)
-				if err != nil {
-					log.Println("cache:", err)
-				}
-				noOfKeysToDel++
-			}
-
-		}
-
-		if cursor == 0 {
-			break
-		}
-
-	}
-
-}
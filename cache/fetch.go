@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats counts how the fetched values were served
+type Stats struct {
+	// Hits were served from fresh cached values
+	Hits uint64 `json:"hits"`
+	// Misses were loaded while the request waited, concurrent misses of a key share one load
+	Misses uint64 `json:"misses"`
+	// Stale were served from cached values past their soft ttl while they were refreshed
+	Stale uint64 `json:"stale"`
+	// Errors are the failed store operations, the values were loaded without the cache
+	Errors uint64 `json:"errors"`
+}
+
+// Fetcher reads values through a Store, coalescing the concurrent loads of a key.
+// Values are fresh until their soft ttl, then they are served stale while one goroutine
+// refreshes them, until the store drops them after their hard ttl.
+type Fetcher struct {
+	store   Store
+	softTTL time.Duration
+	hardTTL time.Duration
+	group   singleflight.Group
+	stats   Stats
+	now     func() time.Time
+}
+
+// NewFetcher returns a Fetcher on a store, a hard ttl shorter than the soft one disables stale values
+func NewFetcher(store Store, softTTL, hardTTL time.Duration) *Fetcher {
+	if hardTTL < softTTL {
+		hardTTL = softTTL
+	}
+	return &Fetcher{store: store, softTTL: softTTL, hardTTL: hardTTL, now: time.Now}
+}
+
+// defaultFetcher reads through the Backend
+var defaultFetcher *Fetcher
+
+// SetBackend replaces the Backend along with the fetcher that reads through it
+func SetBackend(store Store) {
+	Backend = store
+	defaultFetcher = NewFetcher(store, fetchConfig.SoftTTL, fetchConfig.HardTTL)
+}
+
+// Fetch returns the value of a key from the Backend, loading and caching it with its tags when it's missing
+func Fetch(key string, load func() (string, error), tags ...string) (string, error) {
+	return defaultFetcher.Fetch(key, load, tags...)
+}
+
+// FetchStats returns the counters of the values fetched from the Backend
+func FetchStats() Stats {
+	return defaultFetcher.Stats()
+}
+
+// Fetch returns the value of a key, loading and caching it with its tags when it's missing
+func (f *Fetcher) Fetch(key string, load func() (string, error), tags ...string) (string, error) {
+	cached, ok, err := f.store.Get(key)
+	if err != nil {
+		atomic.AddUint64(&f.stats.Errors, 1)
+		fmt.Println(errors.WithMessage(err, "cache: get operation failed"))
+	}
+	if ok {
+		if freshUntil, value, valid := decodeFetched(cached); valid {
+			if f.now().Before(freshUntil) {
+				atomic.AddUint64(&f.stats.Hits, 1)
+				return value, nil
+			}
+			atomic.AddUint64(&f.stats.Stale, 1)
+			go f.load(key, load, tags)
+			return value, nil
+		}
+	}
+
+	atomic.AddUint64(&f.stats.Misses, 1)
+	return f.load(key, load, tags)
+}
+
+// Stats returns a snapshot of the counters
+func (f *Fetcher) Stats() Stats {
+	return Stats{
+		Hits:   atomic.LoadUint64(&f.stats.Hits),
+		Misses: atomic.LoadUint64(&f.stats.Misses),
+		Stale:  atomic.LoadUint64(&f.stats.Stale),
+		Errors: atomic.LoadUint64(&f.stats.Errors),
+	}
+}
+
+// Loads and caches a value, a single load of a key runs at a time
+func (f *Fetcher) load(key string, load func() (string, error), tags []string) (string, error) {
+	value, err, _ := f.group.Do(key, func() (interface{}, error) {
+		// Read before loading, so that a value loaded before an invalidation of its tags isn't cached after it
+		generation, generationErr := f.store.Generation(tags...)
+		if generationErr != nil {
+			atomic.AddUint64(&f.stats.Errors, 1)
+			fmt.Println(errors.WithMessage(generationErr, "cache: generation operation failed"))
+		}
+
+		value, err := load()
+		if err != nil {
+			return "", err
+		}
+		if generationErr != nil {
+			return value, nil
+		}
+		if _, err := f.store.SetIfGeneration(key, encodeFetched(f.now().Add(f.softTTL), value), f.hardTTL, generation, tags...); err != nil {
+			atomic.AddUint64(&f.stats.Errors, 1)
+			fmt.Println(errors.WithMessage(err, "cache: set operation failed"))
+		}
+		return value, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return value.(string), nil
+}
+
+// Prefixes a value with the time it's fresh until
+func encodeFetched(freshUntil time.Time, value string) string {
+	return strconv.FormatInt(freshUntil.UnixNano(), 10) + "\n" + value
+}
+
+// Splits a cached value into the time it's fresh until and the value
+func decodeFetched(cached string) (time.Time, string, bool) {
+	tmp := strings.SplitN(cached, "\n", 2)
+	if len(tmp) != 2 {
+		return time.Time{}, "", false
+	}
+	freshUntil, err := strconv.ParseInt(tmp[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return time.Unix(0, freshUntil), tmp[1], true
+}
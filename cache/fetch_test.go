@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetcher(t *testing.T) {
+	store := newMemoryStore(10)
+	fetcher := NewFetcher(store, time.Minute, time.Hour)
+	now := time.Now()
+	fetcher.now = func() time.Time { return now }
+
+	loads := 0
+	load := func() (string, error) {
+		loads++
+		return "value", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		value, err := fetcher.Fetch("issues:1", load, IssuesTag)
+		if err != nil || value != "value" {
+			t.Fatalf("expected the loaded value, got %q, %v", value, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected a single load, got %d", loads)
+	}
+	if stats := fetcher.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("expected a hit and a miss, got %+v", stats)
+	}
+
+	// Invalidated values are loaded again
+	store.Invalidate(IssuesTag)
+	fetcher.Fetch("issues:1", load, IssuesTag)
+	if loads != 2 {
+		t.Errorf("expected the invalidated value to be loaded, got %d loads", loads)
+	}
+
+	// Values that can't be decoded are loaded again
+	store.Set("issues:2", "legacy", time.Hour)
+	if value, _ := fetcher.Fetch("issues:2", load); value != "value" {
+		t.Errorf("expected the legacy value to be replaced, got %q", value)
+	}
+
+	failing := func() (string, error) { return "", errors.New("db is down") }
+	if _, err := fetcher.Fetch("issues:3", failing); err == nil {
+		t.Error("expected the load error")
+	}
+	if ok, _ := store.Exists("issues:3"); ok {
+		t.Error("expected failed loads not to be cached")
+	}
+}
+
+func TestFetcherStale(t *testing.T) {
+	store := newMemoryStore(10)
+	fetcher := NewFetcher(store, time.Minute, time.Hour)
+	now := time.Now()
+	fetcher.now = func() time.Time { return now }
+
+	fetcher.Fetch("issues:1", func() (string, error) { return "old", nil })
+	now = now.Add(2 * time.Minute)
+
+	refreshed := make(chan struct{})
+	value, err := fetcher.Fetch("issues:1", func() (string, error) {
+		defer close(refreshed)
+		return "new", nil
+	})
+	if err != nil || value != "old" {
+		t.Errorf("expected the stale value, got %q, %v", value, err)
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stale value to be refreshed")
+	}
+	// Waits for the refresh to be stored
+	for i := 0; i < 100; i++ {
+		cached, _, _ := store.Get("issues:1")
+		if _, value, _ := decodeFetched(cached); value == "new" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	value, _ = fetcher.Fetch("issues:1", func() (string, error) { return "", errors.New("unexpected load") })
+	if value != "new" {
+		t.Errorf("expected the refreshed value, got %q", value)
+	}
+	if stats := fetcher.Stats(); stats.Stale != 1 || stats.Hits != 1 {
+		t.Errorf("expected the refreshed value to be a hit, got %+v", stats)
+	}
+}
+
+func TestFetcherCoalescing(t *testing.T) {
+	fetcher := NewFetcher(newMemoryStore(10), time.Minute, time.Hour)
+
+	var loads int32
+	release := make(chan struct{})
+	load := func() (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := fetcher.Fetch("issues:1", load); err != nil || value != "value" {
+				t.Errorf("expected the loaded value, got %q, %v", value, err)
+			}
+		}()
+	}
+	// Lets the requests pile up on the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("expected the concurrent misses to share a load, got %d loads", loads)
+	}
+}
+
+func TestFetcherInvalidatedWhileLoading(t *testing.T) {
+	store := newMemoryStore(10)
+	fetcher := NewFetcher(store, time.Minute, time.Hour)
+
+	// The tag is invalidated after the load read the rows, like a transaction committed meanwhile
+	stale := func() (string, error) {
+		store.Invalidate(ProjectTag("a"))
+		return "stale", nil
+	}
+	if value, err := fetcher.Fetch("project-detail:a", stale, ProjectTag("a")); err != nil || value != "stale" {
+		t.Fatalf("expected the loaded value, got %q, %v", value, err)
+	}
+	if ok, _ := store.Exists("project-detail:a"); ok {
+		t.Error("expected the value loaded before the invalidation not to be cached")
+	}
+
+	fresh := func() (string, error) { return "fresh", nil }
+	fetcher.Fetch("project-detail:a", fresh, ProjectTag("a"))
+	if value, _ := fetcher.Fetch("project-detail:a", stale, ProjectTag("a")); value != "fresh" {
+		t.Errorf("expected the value loaded after the invalidation to be cached, got %q", value)
+	}
+}
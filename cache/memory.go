@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"container/list"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryEntry is a value of the memory store
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time
+	tags      []string
+}
+
+// memoryStore is an in-process Store that evicts the least recently used keys once it's full
+type memoryStore struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	// order holds the entries, the most recently used first
+	order *list.List
+	// tags maps a tag to its keys
+	tags map[string]map[string]bool
+	// generations counts the invalidations of the tags
+	generations map[string]uint64
+	now         func() time.Time
+}
+
+// NewMemoryStore returns a Store that keeps up to size values in memory
+func NewMemoryStore(size int) Store {
+	return newMemoryStore(size)
+}
+
+func newMemoryStore(size int) *memoryStore {
+	if size < 1 {
+		size = 1
+	}
+	return &memoryStore{
+		size:        size,
+		entries:     map[string]*list.Element{},
+		order:       list.New(),
+		tags:        map[string]map[string]bool{},
+		generations: map[string]uint64{},
+		now:         time.Now,
+	}
+}
+
+// Returns the entry of a key that hasn't expired, the caller must hold the lock
+func (s *memoryStore) entry(key string) *memoryEntry {
+	element, exists := s.entries[key]
+	if !exists {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !s.now().Before(entry.expiresAt) {
+		s.remove(element)
+		return nil
+	}
+	s.order.MoveToFront(element)
+	return entry
+}
+
+// Removes an entry along with its tags, the caller must hold the lock
+func (s *memoryStore) remove(element *list.Element) {
+	entry := s.order.Remove(element).(*memoryEntry)
+	delete(s.entries, entry.key)
+	for _, tag := range entry.tags {
+		delete(s.tags[tag], entry.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+func (s *memoryStore) Get(key string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entry(key)
+	if entry == nil {
+		return "", false, nil
+	}
+	return entry.value, true, nil
+}
+
+func (s *memoryStore) Set(key, value string, ttl time.Duration, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl, tags)
+	return nil
+}
+
+// Sets the entry of a key, the caller must hold the lock
+func (s *memoryStore) set(key, value string, ttl time.Duration, tags []string) {
+	if element, exists := s.entries[key]; exists {
+		s.remove(element)
+	}
+	entry := &memoryEntry{key: key, value: value, expiresAt: s.now().Add(ttl), tags: tags}
+	s.entries[key] = s.order.PushFront(entry)
+	for _, tag := range tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = map[string]bool{}
+		}
+		s.tags[tag][key] = true
+	}
+
+	for s.order.Len() > s.size {
+		s.remove(s.order.Back())
+	}
+}
+
+func (s *memoryStore) Exists(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.entry(key) != nil, nil
+}
+
+func (s *memoryStore) Delete(keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if element, exists := s.entries[key]; exists {
+			s.remove(element)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Invalidate(tags ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for _, tag := range tags {
+		s.generations[tag]++
+		for key := range s.tags[tag] {
+			if element, exists := s.entries[key]; exists {
+				s.remove(element)
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func (s *memoryStore) Generation(tags ...string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.generation(tags), nil
+}
+
+// Returns the generation of the tags, the caller must hold the lock
+func (s *memoryStore) generation(tags []string) string {
+	parts := make([]string, len(tags))
+	for i, tag := range tags {
+		parts[i] = strconv.FormatUint(s.generations[tag], 10)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (s *memoryStore) SetIfGeneration(key, value string, ttl time.Duration, generation string, tags ...string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.generation(tags) != generation {
+		return false, nil
+	}
+	s.set(key, value, ttl, tags)
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := newMemoryStore(10)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if err := store.Set("issues:1", "value", time.Minute, IssuesTag); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := store.Get("issues:1")
+	if err != nil || !ok || value != "value" {
+		t.Errorf("expected the cached value, got %q, %v, %v", value, ok, err)
+	}
+	if _, ok, _ := store.Get("issues:2"); ok {
+		t.Error("expected a missing key not to be found")
+	}
+
+	now = now.Add(time.Minute)
+	if ok, _ := store.Exists("issues:1"); ok {
+		t.Error("expected the key to expire")
+	}
+	if len(store.tags) != 0 {
+		t.Errorf("expected the tags of expired keys to be removed, got %v", store.tags)
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := newMemoryStore(2)
+
+	store.Set("a", "1", time.Minute)
+	store.Set("b", "2", time.Minute)
+	// Reading a makes b the least recently used key
+	store.Get("a")
+	store.Set("c", "3", time.Minute)
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		if ok, _ := store.Exists(key); ok != expected {
+			t.Errorf("expected %s to exist: %v", key, expected)
+		}
+	}
+}
+
+func TestMemoryStoreInvalidate(t *testing.T) {
+	store := newMemoryStore(10)
+
+	store.Set("issues:all", "1", time.Minute, IssuesTag)
+	store.Set("issues:a", "2", time.Minute, ProjectTag("a"))
+	store.Set("issues:b", "3", time.Minute, ProjectTag("b"))
+	store.Set("issues-count:a", "4", time.Minute, ProjectTag("a"), ProjectTag("c"))
+
+	deleted, err := store.Invalidate(IssuesTag, ProjectTag("a"))
+	if err != nil || deleted != 3 {
+		t.Errorf("expected 3 deleted keys, got %d, %v", deleted, err)
+	}
+	if ok, _ := store.Exists("issues:b"); !ok {
+		t.Error("expected the key of the other project to be kept")
+	}
+	if _, exists := store.tags[ProjectTag("c")]; exists {
+		t.Error("expected the other tags of the deleted keys to be removed")
+	}
+
+	store.Delete("issues:b")
+	if store.order.Len() != 0 {
+		t.Errorf("expected the store to be empty, got %d entries", store.order.Len())
+	}
+}
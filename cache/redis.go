@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// redisStore is a Store on a pool of redis connections
+type redisStore struct {
+	pool *redis.Pool
+}
+
+// NewRedisStore returns a Store that keeps the values in redis
+func NewRedisStore(pool *redis.Pool) Store {
+	return &redisStore{pool: pool}
+}
+
+func (s *redisStore) Get(key string) (string, bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	value, err := GetString(&conn, key)
+	if err == redis.ErrNil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(key, value string, ttl time.Duration, tags ...string) error {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return SetExTagged(&conn, key, ttlSeconds(ttl), value, tags...)
+}
+
+func (s *redisStore) Exists(key string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return Exists(&conn, key)
+}
+
+func (s *redisStore) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("UNLINK", redis.Args{}.AddFlat(keys)...)
+	return err
+}
+
+func (s *redisStore) Invalidate(tags ...string) (int, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return InvalidateTags(&conn, tags...)
+}
+
+func (s *redisStore) Generation(tags ...string) (string, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return TagsGeneration(&conn, tags...)
+}
+
+func (s *redisStore) SetIfGeneration(key, value string, ttl time.Duration, generation string, tags ...string) (bool, error) {
+	conn := s.pool.Get()
+	defer conn.Close()
+
+	return SetExTaggedIfGeneration(&conn, key, ttlSeconds(ttl), value, generation, tags...)
+}
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+func TestRedisStore(t *testing.T) {
+	fake := newFakeRedis()
+	store := NewRedisStore(&redis.Pool{Dial: func() (redis.Conn, error) { return fake, nil }})
+
+	if _, ok, err := store.Get("issues:1"); ok || err != nil {
+		t.Errorf("expected a missing key not to be found, got %v, %v", ok, err)
+	}
+
+	if err := store.Set("issues:1", "value", 1500*time.Millisecond, IssuesTag); err != nil {
+		t.Fatal(err)
+	}
+	if fake.ttls["issues:1"] != 2 {
+		t.Errorf("expected the ttl to be rounded up to 2 seconds, got %d", fake.ttls["issues:1"])
+	}
+	value, ok, err := store.Get("issues:1")
+	if err != nil || !ok || value != "value" {
+		t.Errorf("expected the cached value, got %q, %v, %v", value, ok, err)
+	}
+
+	if deleted, err := store.Invalidate(IssuesTag); err != nil || deleted != 1 {
+		t.Errorf("expected 1 deleted key, got %d, %v", deleted, err)
+	}
+	if ok, _ := store.Exists("issues:1"); ok {
+		t.Error("expected the key to be invalidated")
+	}
+
+	generation, err := store.Generation(IssuesTag)
+	if err != nil || generation != "1" {
+		t.Errorf("expected the invalidation to bump the generation, got %q, %v", generation, err)
+	}
+	if set, err := store.SetIfGeneration("issues:1", "value", time.Minute, "", IssuesTag); err != nil || set {
+		t.Errorf("expected a value of a previous generation not to be set, got %v, %v", set, err)
+	}
+	if set, err := store.SetIfGeneration("issues:1", "value", time.Minute, generation, IssuesTag); err != nil || !set {
+		t.Errorf("expected the value to be set, got %v, %v", set, err)
+	}
+}
+
+func TestRedisStoreUnavailable(t *testing.T) {
+	store := NewRedisStore(&redis.Pool{Dial: func() (redis.Conn, error) {
+		return redis.Dial("tcp", "127.0.0.1:1", redis.DialConnectTimeout(100*time.Millisecond))
+	}})
+
+	if _, _, err := store.Get("issues:1"); err == nil {
+		t.Error("expected an error when redis is unavailable")
+	}
+	if err := store.Set("issues:1", "value", time.Minute); err == nil {
+		t.Error("expected an error when redis is unavailable")
+	}
+}
@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"time"
+)
+
+// Store is a cache of string values with an expiry time and tags to invalidate them by.
+// Errors mean that the store is unavailable, callers should carry on without the cache.
+type Store interface {
+	// Get returns the value of a key and whether it was found
+	Get(key string) (string, bool, error)
+	// Set sets the value of a key for ttl and adds the key to its tags
+	Set(key, value string, ttl time.Duration, tags ...string) error
+	Exists(key string) (bool, error)
+	Delete(keys ...string) error
+	// Invalidate deletes the keys of the tags and returns the number of deleted keys
+	Invalidate(tags ...string) (int, error)
+	// Generation returns a snapshot of the tags that changes whenever one of them is invalidated
+	Generation(tags ...string) (string, error)
+	// SetIfGeneration sets a key like Set unless one of its tags was invalidated since the generation was read,
+	// and reports whether it was set
+	SetIfGeneration(key, value string, ttl time.Duration, generation string, tags ...string) (bool, error)
+}
+
+// Rounds a ttl up to whole seconds, redis expiry times can't be shorter than a second
+func ttlSeconds(ttl time.Duration) int {
+	seconds := int((ttl + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// IssuesTag tags the cached entries that can contain the issues of any project
+const IssuesTag = "issues"
+
+// tagSetTTL is the minimum time a tag set outlives the keys added to it
+const tagSetTTL = 24 * 60 * 60
+
+// unlinkBatchSize is the number of keys deleted by each UNLINK of an invalidation
+const unlinkBatchSize = 500
+
+// ProjectTag tags the cached entries built from the issues of a project
+func ProjectTag(projectID string) string {
+	return "project:" + projectID
+}
+
+// Returns the key of the set that holds the keys of a tag
+func tagKey(tag string) string {
+	return "tag:" + tag
+}
+
+// Returns the key of the counter of the invalidations of a tag
+func generationKey(tag string) string {
+	return "tag-generation:" + tag
+}
+
+// SetExTagged sets a key with an expiry time in seconds and adds it to the sets of its tags
+func SetExTagged(RConn *redis.Conn, key string, ttl int, data interface{}, tags ...string) error {
+	if err := sendSetExTagged(*RConn, key, ttl, data, tags); err != nil {
+		return err
+	}
+	_, err := (*RConn).Do("EXEC")
+	return err
+}
+
+// SetExTaggedIfGeneration is SetExTagged unless one of the tags was invalidated since their generation was read,
+// it reports whether the key was set
+func SetExTaggedIfGeneration(RConn *redis.Conn, key string, ttl int, data interface{}, generation string, tags ...string) (bool, error) {
+	conn := *RConn
+	if len(tags) > 0 {
+		// The transaction is aborted when the generation changes after it was compared
+		keys := redis.Args{}
+		for _, tag := range tags {
+			keys = keys.Add(generationKey(tag))
+		}
+		if _, err := conn.Do("WATCH", keys...); err != nil {
+			return false, err
+		}
+		current, err := TagsGeneration(RConn, tags...)
+		if err != nil || current != generation {
+			conn.Do("UNWATCH")
+			return false, err
+		}
+	}
+
+	if err := sendSetExTagged(conn, key, ttl, data, tags); err != nil {
+		return false, err
+	}
+	reply, err := conn.Do("EXEC")
+	return reply != nil, err
+}
+
+// Queues the commands of SetExTagged in a transaction, the caller executes it
+func sendSetExTagged(conn redis.Conn, key string, ttl int, data interface{}, tags []string) error {
+	tagTTL := ttl
+	if tagTTL < tagSetTTL {
+		tagTTL = tagSetTTL
+	}
+
+	if err := conn.Send("MULTI"); err != nil {
+		return err
+	}
+	if err := conn.Send("SETEX", key, ttl, data); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := conn.Send("SADD", tagKey(tag), key); err != nil {
+			return err
+		}
+		if err := conn.Send("EXPIRE", tagKey(tag), tagTTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TagsGeneration returns a snapshot of the tags that changes whenever one of them is invalidated
+func TagsGeneration(RConn *redis.Conn, tags ...string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+	keys := redis.Args{}
+	for _, tag := range tags {
+		keys = keys.Add(generationKey(tag))
+	}
+	generations, err := redis.Strings((*RConn).Do("MGET", keys...))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(generations, ","), nil
+}
+
+// InvalidateTags deletes every key of the tags along with the tag sets and returns the number of deleted keys
+func InvalidateTags(RConn *redis.Conn, tags ...string) (int, error) {
+	conn := *RConn
+	if len(tags) == 0 {
+		return 0, nil
+	}
+
+	// Reads and removes the tag sets atomically, so keys tagged meanwhile end up in a new set.
+	// The generations of the tags are bumped along, so that the values loaded before can't be cached after.
+	if err := conn.Send("MULTI"); err != nil {
+		return 0, err
+	}
+	for _, tag := range tags {
+		if err := conn.Send("SMEMBERS", tagKey(tag)); err != nil {
+			return 0, err
+		}
+		if err := conn.Send("UNLINK", tagKey(tag)); err != nil {
+			return 0, err
+		}
+		if err := conn.Send("INCR", generationKey(tag)); err != nil {
+			return 0, err
+		}
+	}
+	replies, err := redis.Values(conn.Do("EXEC"))
+	if err != nil {
+		return 0, err
+	}
+
+	seen := map[string]bool{}
+	keys := []interface{}{}
+	for i := 0; i < len(replies); i += 3 {
+		members, err := redis.Strings(replies[i], nil)
+		if err != nil {
+			return 0, err
+		}
+		for _, member := range members {
+			if !seen[member] {
+				seen[member] = true
+				keys = append(keys, member)
+			}
+		}
+	}
+
+	batches := 0
+	for start := 0; start < len(keys); start += unlinkBatchSize {
+		end := start + unlinkBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := conn.Send("UNLINK", keys[start:end]...); err != nil {
+			return 0, err
+		}
+		batches++
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for i := 0; i < batches; i++ {
+		count, err := redis.Int(conn.Receive())
+		if err != nil {
+			return deleted, err
+		}
+		deleted += count
+	}
+	return deleted, nil
+}
@@ -0,0 +1,311 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+type fakeCommand struct {
+	name string
+	args []interface{}
+}
+
+// fakeRedis is an in-process stand-in for a redis connection with the commands the cache uses
+type fakeRedis struct {
+	values   map[string]string
+	ttls     map[string]int
+	sets     map[string]map[string]bool
+	pending  []fakeCommand
+	received []interface{}
+	queued   []fakeCommand
+	multi    bool
+	// watched holds the values of the watched keys when they were watched
+	watched map[string]string
+	// commands holds the name of every executed command
+	commands []string
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{values: map[string]string{}, ttls: map[string]int{}, sets: map[string]map[string]bool{}}
+}
+
+func (f *fakeRedis) Close() error { return nil }
+func (f *fakeRedis) Err() error   { return nil }
+
+func (f *fakeRedis) Send(name string, args ...interface{}) error {
+	f.pending = append(f.pending, fakeCommand{name: name, args: args})
+	return nil
+}
+
+func (f *fakeRedis) Flush() error {
+	for _, command := range f.pending {
+		f.received = append(f.received, f.execute(command))
+	}
+	f.pending = nil
+	return nil
+}
+
+func (f *fakeRedis) Receive() (interface{}, error) {
+	if len(f.received) == 0 {
+		return nil, errors.New("no pending replies")
+	}
+	reply := f.received[0]
+	f.received = f.received[1:]
+	if err, ok := reply.(redis.Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Do flushes the pending commands and drops their replies like redigo does
+func (f *fakeRedis) Do(name string, args ...interface{}) (interface{}, error) {
+	f.Flush()
+	f.received = nil
+	reply := f.execute(fakeCommand{name: name, args: args})
+	if err, ok := reply.(redis.Error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (f *fakeRedis) execute(command fakeCommand) interface{} {
+	name := strings.ToUpper(command.name)
+	if f.multi && name != "EXEC" {
+		f.queued = append(f.queued, command)
+		return "QUEUED"
+	}
+	f.commands = append(f.commands, name)
+
+	args := make([]string, len(command.args))
+	for i, arg := range command.args {
+		args[i] = fmt.Sprint(arg)
+	}
+	switch name {
+	case "MULTI":
+		f.multi = true
+		return "OK"
+	case "EXEC":
+		f.multi = false
+		watched := f.watched
+		f.watched = nil
+		for key, value := range watched {
+			if f.values[key] != value {
+				f.queued = nil
+				return nil
+			}
+		}
+		replies := []interface{}{}
+		for _, queued := range f.queued {
+			replies = append(replies, f.execute(queued))
+		}
+		f.queued = nil
+		return replies
+	case "WATCH":
+		if f.watched == nil {
+			f.watched = map[string]string{}
+		}
+		for _, key := range args {
+			f.watched[key] = f.values[key]
+		}
+		return "OK"
+	case "UNWATCH":
+		f.watched = nil
+		return "OK"
+	case "INCR":
+		var count int64
+		fmt.Sscan(f.values[args[0]], &count)
+		count++
+		f.values[args[0]] = fmt.Sprint(count)
+		return count
+	case "MGET":
+		values := []interface{}{}
+		for _, key := range args {
+			if value, exists := f.values[key]; exists {
+				values = append(values, []byte(value))
+			} else {
+				values = append(values, nil)
+			}
+		}
+		return values
+	case "SETEX":
+		var ttl int
+		fmt.Sscan(args[1], &ttl)
+		f.values[args[0]] = args[2]
+		f.ttls[args[0]] = ttl
+		return "OK"
+	case "GET":
+		if value, exists := f.values[args[0]]; exists {
+			return []byte(value)
+		}
+		return nil
+	case "EXISTS":
+		if _, exists := f.values[args[0]]; exists {
+			return int64(1)
+		}
+		return int64(0)
+	case "SADD":
+		if f.sets[args[0]] == nil {
+			f.sets[args[0]] = map[string]bool{}
+		}
+		for _, member := range args[1:] {
+			f.sets[args[0]][member] = true
+		}
+		return int64(1)
+	case "SMEMBERS":
+		members := []interface{}{}
+		for member := range f.sets[args[0]] {
+			members = append(members, []byte(member))
+		}
+		return members
+	case "EXPIRE":
+		var ttl int
+		fmt.Sscan(args[1], &ttl)
+		f.ttls[args[0]] = ttl
+		return int64(1)
+	case "UNLINK":
+		deleted := int64(0)
+		for _, key := range args {
+			if _, exists := f.values[key]; exists {
+				delete(f.values, key)
+				deleted++
+			}
+			if _, exists := f.sets[key]; exists {
+				delete(f.sets, key)
+				deleted++
+			}
+		}
+		return deleted
+	}
+	return redis.Error("ERR unknown command " + name)
+}
+
+// Returns the cached keys, the generations of the tags are left out
+func (f *fakeRedis) keys() []string {
+	keys := []string{}
+	for key := range f.values {
+		if !strings.HasPrefix(key, generationKey("")) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestInvalidateTags(t *testing.T) {
+	fake := newFakeRedis()
+	var conn redis.Conn = fake
+
+	entries := map[string][]string{
+		"issues:all":       {IssuesTag},
+		"issues:project-a": {ProjectTag("a")},
+		"issues:project-b": {ProjectTag("b")},
+		"issues-count:a":   {ProjectTag("a"), ProjectTag("c")},
+	}
+	for key, tags := range entries {
+		if err := SetExTagged(&conn, key, 600, "value", tags...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.ttls[tagKey(IssuesTag)] != tagSetTTL {
+		t.Errorf("expected the tag set to outlive its keys, got a ttl of %d", fake.ttls[tagKey(IssuesTag)])
+	}
+
+	deleted, err := InvalidateTags(&conn, IssuesTag, ProjectTag("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted keys, got %d", deleted)
+	}
+	if keys := fake.keys(); len(keys) != 1 || keys[0] != "issues:project-b" {
+		t.Errorf("expected only the key of the other project to be left, got %v", keys)
+	}
+	if _, exists := fake.sets[tagKey(ProjectTag("a"))]; exists {
+		t.Error("expected the invalidated tag set to be deleted")
+	}
+	if len(fake.pending) != 0 || len(fake.received) != 0 {
+		t.Error("expected the pipeline to be flushed and every reply to be read")
+	}
+
+	// Invalidating tags without keys is a no-op
+	deleted, err = InvalidateTags(&conn, ProjectTag("d"))
+	if err != nil || deleted != 0 {
+		t.Errorf("expected nothing to be deleted, got %d, %v", deleted, err)
+	}
+}
+
+func TestInvalidateTagsInBatches(t *testing.T) {
+	fake := newFakeRedis()
+	var conn redis.Conn = fake
+
+	for i := 0; i < unlinkBatchSize+10; i++ {
+		if err := SetExTagged(&conn, fmt.Sprintf("issues:%d", i), 600, i, IssuesTag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake.commands = nil
+
+	deleted, err := InvalidateTags(&conn, IssuesTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != unlinkBatchSize+10 {
+		t.Errorf("expected %d deleted keys, got %d", unlinkBatchSize+10, deleted)
+	}
+	unlinks := 0
+	for _, command := range fake.commands {
+		if command == "UNLINK" {
+			unlinks++
+		}
+	}
+	// One for the tag set and two batches of keys
+	if unlinks != 3 {
+		t.Errorf("expected 3 UNLINK commands, got %d", unlinks)
+	}
+	if len(fake.keys()) != 0 {
+		t.Errorf("expected every key to be deleted, got %v", fake.keys())
+	}
+}
+
+func TestSetExTaggedIfGeneration(t *testing.T) {
+	fake := newFakeRedis()
+	var conn redis.Conn = fake
+
+	generation, err := TagsGeneration(&conn, IssuesTag, ProjectTag("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set, err := SetExTaggedIfGeneration(&conn, "issues:all", 600, "value", generation, IssuesTag, ProjectTag("a"))
+	if err != nil || !set {
+		t.Errorf("expected the key to be set, got %v, %v", set, err)
+	}
+
+	// A value loaded before an invalidation isn't cached after it
+	if _, err := InvalidateTags(&conn, ProjectTag("a")); err != nil {
+		t.Fatal(err)
+	}
+	set, err = SetExTaggedIfGeneration(&conn, "issues:all", 600, "stale", generation, IssuesTag, ProjectTag("a"))
+	if err != nil || set {
+		t.Errorf("expected the stale value not to be set, got %v, %v", set, err)
+	}
+	if keys := fake.keys(); len(keys) != 0 {
+		t.Errorf("expected no cached keys, got %v", keys)
+	}
+
+	// The generation can change between the comparison and the transaction
+	fake.Send("WATCH", generationKey(IssuesTag))
+	fake.Flush()
+	fake.received = nil
+	fake.execute(fakeCommand{name: "INCR", args: []interface{}{generationKey(IssuesTag)}})
+	if err := sendSetExTagged(conn, "issues:all", 600, "stale", []string{IssuesTag}); err != nil {
+		t.Fatal(err)
+	}
+	if reply, _ := conn.Do("EXEC"); reply != nil {
+		t.Error("expected the transaction to be aborted")
+	}
+}
@@ -1,7 +1,10 @@
 package grifts
 
 import (
+	"fmt"
+
 	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
 	"github.com/markbates/grift/grift"
 	"github.com/ossn/fixme_backend/models"
 )
@@ -19,6 +22,18 @@ var _ = grift.Namespace("db", func() {
 		return nil
 	})
 
+	grift.Desc("slugs", "Names the projects that were created before the slugs with the slugs of their names")
+	grift.Add("slugs", func(c *grift.Context) error {
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			count, err := models.BackfillProjectSlugs(tx)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%d projects were named\n", count)
+			return nil
+		})
+	})
+
 })
 
 var repositories = models.Repositories{
@@ -0,0 +1,67 @@
+package grifts
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/markbates/grift/grift"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/ossn/fixme_backend/worker"
+	"github.com/pkg/errors"
+)
+
+var _ = grift.Namespace("repositories", func() {
+
+	grift.Desc("import", "Tracks the repositories of a github organization or user in a project. Usage: repositories:import <project id or slug> <owner> [topic] [min good first issues]")
+	grift.Add("import", func(c *grift.Context) error {
+		if len(c.Args) < 2 {
+			return errors.New("usage: repositories:import <project id or slug> <owner> [topic] [min good first issues]")
+		}
+
+		rule := &models.RepositoryImport{Owner: c.Args[1]}
+		if len(c.Args) > 2 && c.Args[2] != "" {
+			rule.Topic = nulls.NewString(c.Args[2])
+		}
+		if len(c.Args) > 3 {
+			minimum, err := strconv.Atoi(c.Args[3])
+			if err != nil {
+				return errors.New("the min good first issues must be a number")
+			}
+			rule.MinGoodFirstIssues = minimum
+		}
+
+		if err := worker.WorkerInst.InitSources(context.Background()); err != nil {
+			return err
+		}
+
+		return models.DB.Transaction(func(tx *pop.Connection) error {
+			project := &models.Project{}
+			if _, err := models.FindProject(tx, c.Args[0], project); err != nil {
+				return errors.WithMessage(err, "couldn't find the project "+c.Args[0])
+			}
+			rule.ProjectID = project.ID
+
+			verrs, err := tx.ValidateAndCreate(rule)
+			if err != nil {
+				return err
+			}
+			if verrs.HasAny() {
+				return errors.New(verrs.Error())
+			}
+
+			repositories, err := worker.WorkerInst.ImportRepositories(tx, rule)
+			if err != nil {
+				return err
+			}
+			for _, repository := range repositories {
+				fmt.Println("Imported", repository.RepositoryUrl)
+			}
+			fmt.Printf("Imported %d repositories of %s in %s\n", len(repositories), rule.Owner, project.DisplayName)
+			return nil
+		})
+	})
+
+})
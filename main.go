@@ -6,8 +6,11 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/gobuffalo/pop"
 	"github.com/ossn/fixme_backend/actions"
+	"github.com/ossn/fixme_backend/models"
 	"github.com/ossn/fixme_backend/worker"
+	"github.com/pkg/errors"
 )
 
 // main is the starting point to your Buffalo application.
@@ -32,8 +35,10 @@ func main() {
 		}
 	}()
 
-	// Start worker
-	go worker.WorkerInst.Init(ctx, c)
+	backfillProjectSlugs()
+
+	// Start worker, it's set up before the app serves the handlers that use it and polls in the background
+	worker.WorkerInst.Init(ctx, c)
 
 	app := actions.App(ctx)
 	// Start app serve
@@ -41,3 +46,18 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// Names the projects that got placeholder slugs when the slugs were migrated, so that the api never serves them.
+// The slugs are unique, so a failure only leaves the placeholders until the next start.
+func backfillProjectSlugs() {
+	err := models.DB.Transaction(func(tx *pop.Connection) error {
+		count, err := models.BackfillProjectSlugs(tx)
+		if err == nil && count > 0 {
+			log.Printf("%d projects were named\n", count)
+		}
+		return err
+	})
+	if err != nil {
+		log.Println(errors.WithMessage(err, "failed to name the projects"))
+	}
+}
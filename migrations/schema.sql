@@ -16,6 +16,28 @@ SET xmloption = content;
 SET client_min_messages = warning;
 SET row_security = off;
 
+--
+-- Name: issues_search_vector_update(); Type: FUNCTION; Schema: public; Owner: USER
+--
+
+CREATE FUNCTION public.issues_search_vector_update() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ begin new.search_vector := setweight(to_tsvector('english', coalesce(new.title, '')), 'A') || setweight(to_tsvector('english', coalesce(array_to_string(new.labels, ' '), '')), 'B') || setweight(to_tsvector('english', coalesce(new.body, '')), 'C'); return new; end $$;
+
+
+ALTER FUNCTION public.issues_search_vector_update() OWNER TO "USER";
+
+--
+-- Name: record_table_modification(); Type: FUNCTION; Schema: public; Owner: USER
+--
+
+CREATE FUNCTION public.record_table_modification() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$ begin insert into table_modifications (table_name, modified_at) values (tg_table_name, clock_timestamp()) on conflict (table_name) do update set modified_at = excluded.modified_at; return null; end $$;
+
+
+ALTER FUNCTION public.record_table_modification() OWNER TO "USER";
+
 SET default_tablespace = '';
 
 SET default_with_oids = false;
@@ -57,7 +79,11 @@ CREATE TABLE public.issues (
     closed boolean DEFAULT false NOT NULL,
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL,
-    github_updated_at timestamp with time zone NOT NULL
+    github_updated_at timestamp with time zone NOT NULL,
+    search_vector tsvector,
+    github_created_at timestamp with time zone,
+    comments_count integer DEFAULT 0 NOT NULL,
+    reactions_count integer DEFAULT 0 NOT NULL
 );
 
 
@@ -70,6 +96,7 @@ ALTER TABLE public.issues OWNER TO "USER";
 CREATE TABLE public.projects (
     id uuid NOT NULL,
     display_name character varying(150) NOT NULL,
+    slug character varying(150) NOT NULL,
     first_color character varying(14) DEFAULT '#FF614C'::character varying NOT NULL,
     second_color character varying(14),
     description text NOT NULL,
@@ -85,6 +112,21 @@ CREATE TABLE public.projects (
 
 ALTER TABLE public.projects OWNER TO "USER";
 
+--
+-- Name: project_slugs; Type: TABLE; Schema: public; Owner: USER
+--
+
+CREATE TABLE public.project_slugs (
+    id uuid NOT NULL,
+    project_id uuid NOT NULL,
+    slug character varying(150) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.project_slugs OWNER TO "USER";
+
 --
 -- Name: repositories; Type: TABLE; Schema: public; Owner: USER
 --
@@ -97,12 +139,68 @@ CREATE TABLE public.repositories (
     last_parsed timestamp without time zone DEFAULT '1999-01-08 00:00:00'::timestamp without time zone NOT NULL,
     created_at timestamp without time zone NOT NULL,
     updated_at timestamp without time zone NOT NULL,
-    tags character varying[]
+    tags character varying[],
+    issues_updated_at timestamp with time zone,
+    last_full_sync timestamp without time zone DEFAULT '1999-01-08 00:00:00'::timestamp without time zone NOT NULL,
+    status character varying(20) DEFAULT 'active'::character varying NOT NULL,
+    status_message text
 );
 
 
 ALTER TABLE public.repositories OWNER TO "USER";
 
+--
+-- Name: repository_imports; Type: TABLE; Schema: public; Owner: USER
+--
+
+CREATE TABLE public.repository_imports (
+    id uuid NOT NULL,
+    project_id uuid NOT NULL,
+    owner character varying(100) NOT NULL,
+    topic character varying(50),
+    min_good_first_issues integer DEFAULT 0 NOT NULL,
+    last_checked_at timestamp without time zone,
+    last_error text,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.repository_imports OWNER TO "USER";
+
+--
+-- Name: sync_jobs; Type: TABLE; Schema: public; Owner: USER
+--
+
+CREATE TABLE public.sync_jobs (
+    id uuid NOT NULL,
+    repository_id uuid NOT NULL,
+    kind character varying(20) NOT NULL,
+    status character varying(20) DEFAULT 'queued'::character varying NOT NULL,
+    attempts integer DEFAULT 0 NOT NULL,
+    run_at timestamp without time zone NOT NULL,
+    last_error text,
+    last_success_at timestamp without time zone,
+    last_failure_at timestamp without time zone,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
+
+
+ALTER TABLE public.sync_jobs OWNER TO "USER";
+
+--
+-- Name: table_modifications; Type: TABLE; Schema: public; Owner: USER
+--
+
+CREATE TABLE public.table_modifications (
+    table_name character varying(63) NOT NULL,
+    modified_at timestamp with time zone NOT NULL
+);
+
+
+ALTER TABLE public.table_modifications OWNER TO "USER";
+
 --
 -- Name: schema_migration; Type: TABLE; Schema: public; Owner: USER
 --
@@ -130,6 +228,14 @@ ALTER TABLE ONLY public.issues
     ADD CONSTRAINT issues_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: project_slugs project_slugs_pkey; Type: CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.project_slugs
+    ADD CONSTRAINT project_slugs_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: projects projects_pkey; Type: CONSTRAINT; Schema: public; Owner: USER
 --
@@ -146,6 +252,30 @@ ALTER TABLE ONLY public.repositories
     ADD CONSTRAINT repositories_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: sync_jobs sync_jobs_pkey; Type: CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.sync_jobs
+    ADD CONSTRAINT sync_jobs_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: table_modifications table_modifications_pkey; Type: CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.table_modifications
+    ADD CONSTRAINT table_modifications_pkey PRIMARY KEY (table_name);
+
+
+--
+-- Name: repository_imports repository_imports_pkey; Type: CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.repository_imports
+    ADD CONSTRAINT repository_imports_pkey PRIMARY KEY (id);
+
+
 --
 -- Name: index_issue_experience_needed; Type: INDEX; Schema: public; Owner: USER
 --
@@ -167,6 +297,48 @@ CREATE INDEX index_issue_language ON public.issues USING btree (language);
 CREATE INDEX index_issue_type ON public.issues USING btree (type);
 
 
+--
+-- Name: index_issue_search_vector; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE INDEX index_issue_search_vector ON public.issues USING gin (search_vector);
+
+
+--
+-- Name: index_project_slug; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE UNIQUE INDEX index_project_slug ON public.projects USING btree (slug);
+
+
+--
+-- Name: index_project_slugs_slug; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE UNIQUE INDEX index_project_slugs_slug ON public.project_slugs USING btree (slug);
+
+
+--
+-- Name: index_repository_import_project_owner; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE UNIQUE INDEX index_repository_import_project_owner ON public.repository_imports USING btree (project_id, owner);
+
+
+--
+-- Name: index_sync_job_repository_kind; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE UNIQUE INDEX index_sync_job_repository_kind ON public.sync_jobs USING btree (repository_id, kind);
+
+
+--
+-- Name: index_sync_job_status_run_at; Type: INDEX; Schema: public; Owner: USER
+--
+
+CREATE INDEX index_sync_job_status_run_at ON public.sync_jobs USING btree (status, run_at);
+
+
 --
 -- Name: schema_migration_version_idx; Type: INDEX; Schema: public; Owner: USER
 --
@@ -174,6 +346,27 @@ CREATE INDEX index_issue_type ON public.issues USING btree (type);
 CREATE UNIQUE INDEX schema_migration_version_idx ON public.schema_migration USING btree (version);
 
 
+--
+-- Name: issues issues_search_vector_trigger; Type: TRIGGER; Schema: public; Owner: USER
+--
+
+CREATE TRIGGER issues_search_vector_trigger BEFORE INSERT OR UPDATE OF title, body, labels ON public.issues FOR EACH ROW EXECUTE PROCEDURE public.issues_search_vector_update();
+
+
+--
+-- Name: projects projects_modification_trigger; Type: TRIGGER; Schema: public; Owner: USER
+--
+
+CREATE TRIGGER projects_modification_trigger AFTER INSERT OR DELETE OR UPDATE OR TRUNCATE ON public.projects FOR EACH STATEMENT EXECUTE PROCEDURE public.record_table_modification();
+
+
+--
+-- Name: repositories repositories_modification_trigger; Type: TRIGGER; Schema: public; Owner: USER
+--
+
+CREATE TRIGGER repositories_modification_trigger AFTER INSERT OR DELETE OR UPDATE OR TRUNCATE ON public.repositories FOR EACH STATEMENT EXECUTE PROCEDURE public.record_table_modification();
+
+
 --
 -- Name: issues issues_projects_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: USER
 --
@@ -190,6 +383,22 @@ ALTER TABLE ONLY public.issues
     ADD CONSTRAINT issues_repositories_id_fk FOREIGN KEY (repository_id) REFERENCES public.repositories(id) ON UPDATE CASCADE ON DELETE CASCADE;
 
 
+--
+-- Name: project_slugs project_slugs_projects_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.project_slugs
+    ADD CONSTRAINT project_slugs_projects_id_fk FOREIGN KEY (project_id) REFERENCES public.projects(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
+--
+-- Name: repository_imports repository_imports_projects_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.repository_imports
+    ADD CONSTRAINT repository_imports_projects_id_fk FOREIGN KEY (project_id) REFERENCES public.projects(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
 --
 -- Name: repositories repositories_projects_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: USER
 --
@@ -198,6 +407,14 @@ ALTER TABLE ONLY public.repositories
     ADD CONSTRAINT repositories_projects_id_fk FOREIGN KEY (project_id) REFERENCES public.projects(id) ON UPDATE CASCADE ON DELETE CASCADE;
 
 
+--
+-- Name: sync_jobs sync_jobs_repositories_id_fk; Type: FK CONSTRAINT; Schema: public; Owner: USER
+--
+
+ALTER TABLE ONLY public.sync_jobs
+    ADD CONSTRAINT sync_jobs_repositories_id_fk FOREIGN KEY (repository_id) REFERENCES public.repositories(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+
 --
 -- PostgreSQL database dump complete
 --
@@ -16,6 +16,7 @@ type Issue struct {
 	CreatedAt        time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt        time.Time     `json:"updated_at" db:"updated_at"`
 	GithubUpdatedAt  time.Time     `json:"github_updated_at" db:"github_updated_at"`
+	GithubCreatedAt  nulls.Time    `json:"github_created_at" db:"github_created_at"`
 	Title            nulls.String  `json:"title" db:"title"`
 	ExperienceNeeded nulls.String  `json:"experience_needed" db:"experience_needed"`
 	ExpectedTime     nulls.String  `json:"expected_time" db:"expected_time"`
@@ -32,10 +33,21 @@ type Issue struct {
 	Number           int           `json:"number" db:"number"`
 	Closed           bool          `json:"-" db:"closed"`
 	Labels           slices.String `json:"labels" db:"labels"`
+	CommentsCount    int           `json:"comments_count" db:"comments_count"`
+	ReactionsCount   int           `json:"reactions_count" db:"reactions_count"`
+	SearchRank       float64       `json:"search_rank,omitempty" db:"-"`
+	Highlight        *Highlight    `json:"highlight,omitempty" db:"-"`
 }
 
 type Issues []Issue
 
+// Highlight holds the parts of an issue that matched a full-text search, wrapped in <mark> tags.
+// The rest of the text is html escaped.
+type Highlight struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 func (i *Issue) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.Validate(
@@ -16,6 +16,7 @@ type Project struct {
 	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
 	DisplayName   string        `json:"display_name" db:"display_name"`
+	Slug          string        `json:"slug" db:"slug"`
 	FirstColor    string        `json:"first_color" db:"first_color"`
 	SecondColor   nulls.String  `json:"second_color" db:"second_color"`
 	Description   string        `json:"description" db:"description"`
@@ -24,6 +25,8 @@ type Project struct {
 	SetupDuration nulls.String  `json:"setup_duration" db:"setup_duration"`
 	IssuesCount   int           `json:"issues_count" db:"issues_count"`
 	Tags          slices.String `json:"tags" db:"tags"`
+	// previousSlug is the slug the project had before an update
+	previousSlug string `json:"-" db:"-"`
 }
 
 type Projects []Project
@@ -35,15 +38,78 @@ func (p *Project) Validate(tx *pop.Connection) (*validate.Errors, error) {
 		&validators.StringIsPresent{Field: p.Description, Name: "Description"},
 		&validators.StringIsPresent{Field: p.Logo, Name: "Logo"},
 		&validators.StringIsPresent{Field: p.Link, Name: "Link"},
+		&validators.FuncValidator{
+			Field:   p.Slug,
+			Name:    "Slug",
+			Message: "%s is not a valid slug, it must only contain lowercase letters, digits and single dashes",
+			Fn: func() bool {
+				return p.Slug == "" || ValidSlug(p.Slug)
+			},
+		},
 	), nil
 }
 
 // ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
 func (p *Project) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.NewErrors(), nil
+	return p.validateSlugAvailable(tx)
 }
 
 // ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
 func (p *Project) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
-	return validate.NewErrors(), nil
+	return p.validateSlugAvailable(tx)
+}
+
+// Checks that a slug set by an admin isn't used by another project
+func (p *Project) validateSlugAvailable(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	if p.Slug == "" || !ValidSlug(p.Slug) {
+		return verrs, nil
+	}
+	taken, err := slugTaken(tx, p.Slug, p.ID)
+	if err != nil {
+		return verrs, err
+	}
+	if taken {
+		verrs.Add("slug", "Slug is already used by another project")
+	}
+	return verrs, nil
+}
+
+// BeforeCreate generates the slug of the project from its name unless one was set
+func (p *Project) BeforeCreate(tx *pop.Connection) error {
+	if p.Slug != "" {
+		return nil
+	}
+	slug, err := uniqueSlug(tx, p.DisplayName, p.ID)
+	p.Slug = slug
+	return err
+}
+
+// BeforeUpdate generates a new slug when the project is renamed, unless a slug was set along with the name
+func (p *Project) BeforeUpdate(tx *pop.Connection) error {
+	stored := &Project{}
+	if err := tx.Select("slug", "display_name").Find(stored, p.ID); err != nil {
+		return err
+	}
+	p.previousSlug = stored.Slug
+	if p.Slug != "" && (p.Slug != stored.Slug || p.DisplayName == stored.DisplayName) {
+		return nil
+	}
+	slug, err := uniqueSlug(tx, p.DisplayName, p.ID)
+	p.Slug = slug
+	return err
+}
+
+// AfterUpdate keeps the previous slug of the project as a redirect
+func (p *Project) AfterUpdate(tx *pop.Connection) error {
+	previousSlug := p.previousSlug
+	p.previousSlug = ""
+	if previousSlug == "" || previousSlug == p.Slug || previousSlug == placeholderSlug(p.ID) {
+		return nil
+	}
+	// A project taking back one of its old slugs doesn't redirect it anymore
+	if err := tx.RawQuery("delete from project_slugs where project_id = ? and slug = ?", p.ID, p.Slug).Exec(); err != nil {
+		return err
+	}
+	return tx.Create(&ProjectSlug{ProjectID: p.ID, Slug: previousSlug})
 }
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ProjectSlug is a slug a project had before it was renamed, it redirects to the project
+type ProjectSlug struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ProjectID uuid.UUID `json:"project_id" db:"project_id"`
+	Slug      string    `json:"slug" db:"slug"`
+}
+
+type ProjectSlugs []ProjectSlug
+
+const (
+	// maxSlugLength is the size of the slug columns
+	maxSlugLength = 150
+	// maxSlugBaseLength leaves room for the collision suffixes
+	maxSlugBaseLength = 100
+	// defaultSlug is used for the names without any letter or digit
+	defaultSlug = "project"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug reports whether a value can be used as a slug
+func ValidSlug(value string) bool {
+	return len(value) <= maxSlugLength && slugPattern.MatchString(value)
+}
+
+// Slugify turns a name into a lowercase, dash separated slug, accents are dropped
+func Slugify(name string) string {
+	slug := strings.Builder{}
+	dash := false
+	for _, r := range norm.NFD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			dash = false
+			slug.WriteRune(r)
+		default:
+			dash = true
+		}
+	}
+	value := slug.String()
+	if len(value) > maxSlugBaseLength {
+		value = strings.TrimRight(value[:maxSlugBaseLength], "-")
+	}
+	if value == "" {
+		return defaultSlug
+	}
+	return value
+}
+
+// slugTaken reports whether a slug is used by another project, either as its slug or as an old one
+func slugTaken(tx *pop.Connection, slug string, projectID uuid.UUID) (bool, error) {
+	result := struct {
+		Taken bool `db:"taken"`
+	}{}
+	err := tx.RawQuery(`select exists(select 1 from projects where slug = ? and id <> ?)
+		or exists(select 1 from project_slugs where slug = ? and project_id <> ?) as taken`,
+		slug, projectID, slug, projectID).First(&result)
+	return result.Taken, err
+}
+
+// uniqueSlug returns the slug of a name, suffixed with -2, -3... when it's taken by another project
+func uniqueSlug(tx *pop.Connection, name string, projectID uuid.UUID) (string, error) {
+	base := Slugify(name)
+	slug := base
+	for i := 2; ; i++ {
+		taken, err := slugTaken(tx, slug, projectID)
+		if err != nil || !taken {
+			return slug, err
+		}
+		slug = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// placeholderSlug is the slug the projects created before the slugs got until BackfillProjectSlugs names them,
+// the ids keep them unique
+func placeholderSlug(projectID uuid.UUID) string {
+	return "project-" + projectID.String()
+}
+
+// BackfillProjectSlugs replaces the placeholder slugs with the slugs of the names of the projects,
+// the oldest project gets the plain slug when several names have the same one
+func BackfillProjectSlugs(tx *pop.Connection) (int, error) {
+	projects := Projects{}
+	if err := tx.Where("slug = 'project-' || id::text").Order("created_at asc, id asc").All(&projects); err != nil {
+		return 0, err
+	}
+	for _, project := range projects {
+		// The slug is checked against all the current and old slugs, the placeholders included
+		slug, err := uniqueSlug(tx, project.DisplayName, project.ID)
+		if err != nil {
+			return 0, err
+		}
+		// The placeholder isn't kept as a redirect
+		if err := tx.RawQuery("update projects set slug = ? where id = ?", slug, project.ID).Exec(); err != nil {
+			return 0, err
+		}
+	}
+	return len(projects), nil
+}
+
+// ProjectIDsBySlugs maps the slugs, current or old, to the ids of their projects.
+// The slugs that don't belong to any project are missing from the map.
+func ProjectIDsBySlugs(tx *pop.Connection, slugs []string) (map[string]uuid.UUID, error) {
+	ids := map[string]uuid.UUID{}
+	if len(slugs) == 0 {
+		return ids, nil
+	}
+	args := make([]interface{}, len(slugs))
+	for i, slug := range slugs {
+		args[i] = slug
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(slugs)), ",")
+	rows := []struct {
+		Slug string    `db:"slug"`
+		ID   uuid.UUID `db:"id"`
+	}{}
+	err := tx.RawQuery(`select slug, id from projects where slug in (`+placeholders+`)
+		union all select slug, project_id as id from project_slugs where slug in (`+placeholders+`)`, append(args, args...)...).All(&rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		// Current slugs win over old ones
+		if _, exists := ids[row.Slug]; !exists {
+			ids[row.Slug] = row.ID
+		}
+	}
+	return ids, nil
+}
+
+// FindProject finds a project by its id or its slug. When the slug is an old one of the
+// project, redirected is true and the project should be linked with its current slug.
+func FindProject(tx *pop.Connection, idOrSlug string, project *Project) (redirected bool, err error) {
+	if id, err := uuid.FromString(idOrSlug); err == nil {
+		return false, tx.Find(project, id)
+	}
+	slug := strings.ToLower(idOrSlug)
+	if err := tx.Where("slug = ?", slug).First(project); err == nil {
+		return false, nil
+	}
+	oldSlug := &ProjectSlug{}
+	if err := tx.Where("slug = ?", slug).First(oldSlug); err != nil {
+		return false, err
+	}
+	return true, tx.Find(project, oldSlug.ProjectID)
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Slugify(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"Fixme", "fixme"},
+		{"Open Source Software Network", "open-source-software-network"},
+		{"  --Node.js / npm--  ", "node-js-npm"},
+		{"Café Crème", "cafe-creme"},
+		{"C++", "c"},
+		{"日本語", "project"},
+		{"", "project"},
+	}
+
+	for _, tt := range tests {
+		if slug := Slugify(tt.name); slug != tt.expected {
+			t.Errorf("Slugify(%q) = %q, expected %q", tt.name, slug, tt.expected)
+		}
+		if !ValidSlug(Slugify(tt.name)) {
+			t.Errorf("Slugify(%q) isn't a valid slug", tt.name)
+		}
+	}
+
+	long := Slugify("a " + strings.Repeat("b", 200))
+	if len(long) > maxSlugBaseLength || !ValidSlug(long) {
+		t.Errorf("long slug %q isn't truncated", long)
+	}
+}
+
+func Test_ValidSlug(t *testing.T) {
+	for _, slug := range []string{"fixme", "fixme-2", "a1-b2-c3"} {
+		if !ValidSlug(slug) {
+			t.Errorf("%q should be valid", slug)
+		}
+	}
+	for _, slug := range []string{"", "Fixme", "-fixme", "fixme-", "fix--me", "fix me", "6ba7b810-9dad-11d1-80b4-00c04fd430c8'"} {
+		if ValidSlug(slug) {
+			t.Errorf("%q should be invalid", slug)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/gobuffalo/nulls"
 	"github.com/gobuffalo/pop"
 	"github.com/gobuffalo/pop/slices"
 	"github.com/gobuffalo/validate"
@@ -11,27 +12,60 @@ import (
 )
 
 type Repository struct {
-	ID            uuid.UUID     `json:"id" db:"id"`
-	CreatedAt     time.Time     `json:"created_at" db:"created_at"`
-	UpdatedAt     time.Time     `json:"updated_at" db:"updated_at"`
-	RepositoryUrl string        `json:"repository_url" db:"repository_url"`
-	Project       Project       `json:"project" db:"-" belongs_to:"project"`
-	ProjectID     uuid.UUID     `json:"project_id" db:"project_id"`
-	IssueCount    int           `json:"issue_count" db:"issue_count"`
-	Issues        Issues        `json:"issues" db:"-" has_many:"issues"`
-	LastParsed    time.Time     `json:"-" db:"last_parsed"`
-	Tags          slices.String `json:"tags" db:"tags"`
+	ID              uuid.UUID     `json:"id" db:"id"`
+	CreatedAt       time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at" db:"updated_at"`
+	RepositoryUrl   string        `json:"repository_url" db:"repository_url"`
+	Project         Project       `json:"project" db:"-" belongs_to:"project"`
+	ProjectID       uuid.UUID     `json:"project_id" db:"project_id"`
+	IssueCount      int           `json:"issue_count" db:"issue_count"`
+	Issues          Issues        `json:"issues" db:"-" has_many:"issues"`
+	LastParsed      time.Time     `json:"-" db:"last_parsed"`
+	Tags            slices.String `json:"tags" db:"tags"`
+	IssuesUpdatedAt nulls.Time    `json:"-" db:"issues_updated_at"`
+	LastFullSync    time.Time     `json:"-" db:"last_full_sync"`
+	Status          string        `json:"status" db:"status"`
+	StatusMessage   nulls.String  `json:"status_message" db:"status_message"`
 }
 
 type Repositories []Repository
 
+const (
+	// RepositoryStatusActive repositories are synced
+	RepositoryStatusActive = "active"
+	// RepositoryStatusArchived repositories are archived or disabled, their issues are closed
+	RepositoryStatusArchived = "archived"
+	// RepositoryStatusBroken repositories can't be found on their forge
+	RepositoryStatusBroken = "broken"
+)
+
 // Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
 func (r *Repository) Validate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.Validate(
 		&validators.StringIsPresent{Field: r.RepositoryUrl, Name: "RepositoryUrl"},
+		&validators.FuncValidator{
+			Field:   r.Status,
+			Name:    "Status",
+			Message: "%s isn't a repository status",
+			Fn: func() bool {
+				switch r.Status {
+				case "", RepositoryStatusActive, RepositoryStatusArchived, RepositoryStatusBroken:
+					return true
+				}
+				return false
+			},
+		},
 	), nil
 }
 
+// BeforeSave makes the repositories active unless they have a status
+func (r *Repository) BeforeSave(tx *pop.Connection) error {
+	if r.Status == "" {
+		r.Status = RepositoryStatusActive
+	}
+	return nil
+}
+
 // ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
 func (r *Repository) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
 	return validate.NewErrors(), nil
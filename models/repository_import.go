@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gobuffalo/validate"
+	"github.com/gobuffalo/validate/validators"
+	"github.com/gofrs/uuid"
+)
+
+// RepositoryImport is a rule that tracks the repositories of a github organization or user in a project.
+// The repositories are listed again periodically so that the new ones are picked up.
+type RepositoryImport struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ProjectID uuid.UUID `json:"project_id" db:"project_id"`
+	// Owner is the login of the organization or the user
+	Owner string `json:"owner" db:"owner"`
+	// Topic limits the import to the repositories with this topic
+	Topic nulls.String `json:"topic" db:"topic"`
+	// MinGoodFirstIssues limits the import to the repositories with at least this many open good first issues
+	MinGoodFirstIssues int          `json:"min_good_first_issues" db:"min_good_first_issues"`
+	LastCheckedAt      nulls.Time   `json:"last_checked_at" db:"last_checked_at"`
+	LastError          nulls.String `json:"last_error" db:"last_error"`
+}
+
+type RepositoryImports []RepositoryImport
+
+// githubLoginPattern matches the logins of github organizations and users
+const githubLoginPattern = `^[A-Za-z0-9][A-Za-z0-9-]{0,38}$`
+
+// Validate gets run every time you call a "pop.Validate*" (pop.ValidateAndSave, pop.ValidateAndCreate, pop.ValidateAndUpdate) method.
+func (r *RepositoryImport) Validate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.Validate(
+		&validators.RegexMatch{Field: r.Owner, Name: "Owner", Expr: githubLoginPattern, Message: "Owner must be a github login"},
+		&validators.IntIsGreaterThan{Field: r.MinGoodFirstIssues, Name: "MinGoodFirstIssues", Compared: -1},
+	), nil
+}
+
+// ValidateCreate gets run every time you call "pop.ValidateAndCreate" method.
+func (r *RepositoryImport) ValidateCreate(tx *pop.Connection) (*validate.Errors, error) {
+	verrs := validate.NewErrors()
+	count, err := tx.Where("project_id = ? and lower(owner) = lower(?)", r.ProjectID, r.Owner).Count(&RepositoryImport{})
+	if err != nil {
+		return verrs, err
+	}
+	if count > 0 {
+		verrs.Add("owner", "The repositories of "+r.Owner+" are already imported in the project")
+	}
+	return verrs, nil
+}
+
+// ValidateUpdate gets run every time you call "pop.ValidateAndUpdate" method.
+func (r *RepositoryImport) ValidateUpdate(tx *pop.Connection) (*validate.Errors, error) {
+	return validate.NewErrors(), nil
+}
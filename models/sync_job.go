@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/gofrs/uuid"
+)
+
+// SyncJob is a task the worker runs for a repository. Every repository has a single job of each kind,
+// which is queued again once it's done, so the table shows when each task last succeeded or failed.
+type SyncJob struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	RepositoryID uuid.UUID `json:"repository_id" db:"repository_id"`
+	Kind         string    `json:"kind" db:"kind"`
+	Status       string    `json:"status" db:"status"`
+	// Attempts is the number of failures since the last success
+	Attempts      int          `json:"attempts" db:"attempts"`
+	RunAt         time.Time    `json:"run_at" db:"run_at"`
+	LastError     nulls.String `json:"last_error" db:"last_error"`
+	LastSuccessAt nulls.Time   `json:"last_success_at" db:"last_success_at"`
+	LastFailureAt nulls.Time   `json:"last_failure_at" db:"last_failure_at"`
+}
+
+type SyncJobs []SyncJob
+
+const (
+	// SyncJobKindSync jobs sync the issues of a repository
+	SyncJobKindSync = "sync"
+	// SyncJobKindTopics jobs refresh the topics of a repository and the tags of its project
+	SyncJobKindTopics = "topics"
+	// SyncJobKindDangling jobs close the issues that a full sync didn't receive
+	SyncJobKindDangling = "dangling"
+)
+
+const (
+	// SyncJobStatusQueued jobs run once their run_at is reached
+	SyncJobStatusQueued  = "queued"
+	SyncJobStatusRunning = "running"
+	// SyncJobStatusDone jobs ran successfully and aren't repeated
+	SyncJobStatusDone = "done"
+	// SyncJobStatusDead jobs failed too many times, they only run again when an admin queues them
+	SyncJobStatusDead = "dead"
+)
+
+// CreateMissingSyncJobs creates the jobs of a kind for the repositories that don't have one,
+// they are queued in the order the repositories were last synced
+func CreateMissingSyncJobs(tx *pop.Connection, kind string) error {
+	now := time.Now()
+	return tx.RawQuery(`insert into sync_jobs (id, repository_id, kind, status, attempts, run_at, created_at, updated_at)
+		select md5(random()::text || repositories.id::text)::uuid, repositories.id, ?, ?, 0, repositories.last_parsed, ?, ?
+		from repositories
+		on conflict (repository_id, kind) do nothing`, kind, SyncJobStatusQueued, now, now).Exec()
+}
+
+// QueueSyncJobs queues the jobs of a kind to run now for the repositories matching a condition, creating the missing ones.
+// Running jobs are left alone, and so are the dead ones since only the admins bring them back.
+func QueueSyncJobs(tx *pop.Connection, kind string, where string, args ...interface{}) error {
+	now := time.Now()
+	values := append([]interface{}{kind, SyncJobStatusQueued, now, now, now}, args...)
+	values = append(values, SyncJobStatusRunning, SyncJobStatusDead)
+	return tx.RawQuery(`insert into sync_jobs (id, repository_id, kind, status, attempts, run_at, created_at, updated_at)
+		select md5(random()::text || repositories.id::text)::uuid, repositories.id, ?, ?, 0, ?, ?, ?
+		from repositories where `+where+`
+		on conflict (repository_id, kind) do update set status = excluded.status, attempts = 0, run_at = excluded.run_at, updated_at = excluded.updated_at
+		where sync_jobs.status not in (?, ?)`, values...).Exec()
+}
+
+// Requeue makes a job run at a time with a fresh number of attempts, unless it's running already
+func (j *SyncJob) Requeue(tx *pop.Connection, runAt time.Time) error {
+	err := tx.RawQuery("update sync_jobs set status = ?, attempts = 0, run_at = ?, updated_at = ? where id = ? and status <> ?",
+		SyncJobStatusQueued, runAt, time.Now(), j.ID, SyncJobStatusRunning).Exec()
+	if err != nil {
+		return err
+	}
+	return tx.Reload(j)
+}
+
+// Claim marks a job as running when it's still queued and due, and loads it again so that it runs with its current state.
+// It returns false when the job was claimed by another worker or changed since it was read.
+func (j *SyncJob) Claim(tx *pop.Connection) (bool, error) {
+	now := time.Now()
+	count, err := tx.RawQuery("update sync_jobs set status = ?, updated_at = ? where id = ? and status = ? and run_at <= ?",
+		SyncJobStatusRunning, now, j.ID, SyncJobStatusQueued, now).ExecWithCount()
+	if err != nil || count == 0 {
+		return false, err
+	}
+	return true, tx.Reload(j)
+}
+
+// Finish saves the outcome of a running job, the columns the worker doesn't own are left alone
+func (j *SyncJob) Finish(tx *pop.Connection) error {
+	j.UpdatedAt = time.Now()
+	return tx.RawQuery(`update sync_jobs set status = ?, attempts = ?, run_at = ?, last_error = ?, last_success_at = ?, last_failure_at = ?, updated_at = ?
+		where id = ? and status = ?`, j.Status, j.Attempts, j.RunAt, j.LastError, j.LastSuccessAt, j.LastFailureAt, j.UpdatedAt,
+		j.ID, SyncJobStatusRunning).Exec()
+}
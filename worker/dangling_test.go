@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// flakySource answers the issue states of every request after failing the first ones
+type flakySource struct {
+	IssueSource
+	failures int
+	requests [][]int
+	states   map[int]IssueState
+}
+
+func (s *flakySource) IssueStates(ctx context.Context, repo RepositoryRef, numbers []int) (map[int]IssueState, error) {
+	s.requests = append(s.requests, numbers)
+	states := map[int]IssueState{}
+	if len(s.requests) <= s.failures {
+		// Only the first issue is known when the request fails
+		states[numbers[0]] = s.states[numbers[0]]
+		return states, errors.New("connection reset by peer")
+	}
+	for _, number := range numbers {
+		states[number] = s.states[number]
+	}
+	return states, nil
+}
+
+func Test_Worker_IssueStates_Retries(t *testing.T) {
+	defer func(delay time.Duration) { danglingRetryDelay = delay }(danglingRetryDelay)
+	danglingRetryDelay = time.Millisecond
+
+	w := &Worker{ctx: context.Background()}
+	source := &flakySource{failures: 2, states: map[int]IssueState{1: IssueStateClosed, 2: IssueStateOpen, 3: IssueStateMissing}}
+	states, err := w.issueStates(context.Background(), source, RepositoryRef{"github.com", "ossn", "fixme"}, []int{1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 3 || states[3] != IssueStateMissing {
+		t.Errorf("unexpected states %v", states)
+	}
+	// The retries only request the issues that are still unknown
+	if len(source.requests) != 3 || len(source.requests[1]) != 2 || len(source.requests[2]) != 1 {
+		t.Errorf("unexpected requests %v", source.requests)
+	}
+}
+
+func Test_Worker_IssueStates_GivesUp(t *testing.T) {
+	defer func(delay time.Duration) { danglingRetryDelay = delay }(danglingRetryDelay)
+	danglingRetryDelay = time.Millisecond
+
+	w := &Worker{ctx: context.Background()}
+	source := &flakySource{failures: danglingRetries + 1, states: map[int]IssueState{1: IssueStateOpen, 2: IssueStateClosed, 3: IssueStateClosed, 4: IssueStateClosed, 5: IssueStateClosed, 6: IssueStateClosed, 7: IssueStateClosed}}
+	states, err := w.issueStates(context.Background(), source, RepositoryRef{"github.com", "ossn", "fixme"}, []int{1, 2, 3, 4, 5, 6, 7})
+	if err == nil {
+		t.Error("expected the last error")
+	}
+	if len(source.requests) != danglingRetries+1 {
+		t.Errorf("expected %d requests, got %d", danglingRetries+1, len(source.requests))
+	}
+	// The issues that were never checked stay unknown, so they aren't closed
+	if _, known := states[7]; known {
+		t.Errorf("expected issue 7 to be unknown, got %v", states)
+	}
+}
@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// giteaPageSize is the default maximum page size of gitea instances
+const giteaPageSize = 50
+
+type (
+	/**
+	* Gitea REST v1 Types, Forgejo and Codeberg share the same api
+	 */
+
+	giteaIssue struct {
+		ID        int    `json:"id"`
+		Number    int    `json:"number"`
+		Title     string `json:"title"`
+		Body      string `json:"body"`
+		State     string `json:"state"`
+		HTMLURL   string `json:"html_url"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Comments  int    `json:"comments"`
+		Labels    []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+
+	// giteaSource loads issues through the gitea REST v1 API
+	giteaSource struct {
+		restClient
+	}
+)
+
+func newGiteaSource(baseURL, token string) *giteaSource {
+	return &giteaSource{
+		restClient: newRestClient(baseURL, func(req *http.Request) {
+			if token != "" {
+				req.Header.Set("Authorization", "token "+token)
+			}
+		}),
+	}
+}
+
+func repoPath(repo RepositoryRef) string {
+	return "/repos/" + url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name)
+}
+
+func (i giteaIssue) toSourceIssue() SourceIssue {
+	labels := []string{}
+	for _, label := range i.Labels {
+		labels = append(labels, label.Name)
+	}
+	return SourceIssue{
+		ID:        i.ID,
+		Number:    i.Number,
+		Title:     i.Title,
+		Body:      i.Body,
+		Closed:    i.State == "closed",
+		URL:       i.HTMLURL,
+		CreatedAt: timeConvert(i.CreatedAt),
+		UpdatedAt: timeConvert(i.UpdatedAt),
+		Labels:    labels,
+		Comments:  i.Comments,
+	}
+}
+
+// ListIssues pages through the issues of the repository, using the page number as the cursor
+func (s *giteaSource) ListIssues(ctx context.Context, repo RepositoryRef, since time.Time, cursor string) (*IssuePage, error) {
+	page := 1
+	if cursor != "" {
+		var err error
+		if page, err = strconv.Atoi(cursor); err != nil {
+			return nil, errors.Wrap(err, "invalid cursor")
+		}
+	}
+	query := url.Values{}
+	query.Set("state", "all")
+	query.Set("type", "issues")
+	query.Set("limit", strconv.Itoa(giteaPageSize))
+	query.Set("page", strconv.Itoa(page))
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+
+	giteaIssues := []giteaIssue{}
+	header, err := s.get(ctx, repoPath(repo)+"/issues", query, &giteaIssues)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't load issues")
+	}
+
+	issuePage := &IssuePage{}
+	for _, issue := range giteaIssues {
+		issuePage.Issues = append(issuePage.Issues, issue.toSourceIssue())
+	}
+	if hasNextPage(header, page, len(giteaIssues)) {
+		issuePage.NextCursor = strconv.Itoa(page + 1)
+	}
+	return issuePage, nil
+}
+
+// Reports whether another page of issues exists. The instances can cap the pages below the requested limit,
+// so the pagination headers are used rather than the size of the page.
+func hasNextPage(header http.Header, page, count int) bool {
+	if links := header.Get("Link"); links != "" {
+		for _, link := range strings.Split(links, ",") {
+			if strings.Contains(link, `rel="next"`) {
+				return true
+			}
+		}
+		return false
+	}
+	// Every page but the last one has the capped size
+	if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+		return count > 0 && page*count < total
+	}
+	// A full page means that there might be more issues
+	return count == giteaPageSize
+}
+
+func (s *giteaSource) Topics(ctx context.Context, repo RepositoryRef) ([]string, error) {
+	topics := struct {
+		Topics []string `json:"topics"`
+	}{}
+	if _, err := s.get(ctx, repoPath(repo)+"/topics", nil, &topics); err != nil {
+		return nil, errors.WithMessage(err, "couldn't load topics")
+	}
+	return topics.Topics, nil
+}
+
+// PrimaryLanguage returns the language with the most bytes in the repository
+func (s *giteaSource) PrimaryLanguage(ctx context.Context, repo RepositoryRef) (string, error) {
+	languages := map[string]float64{}
+	if _, err := s.get(ctx, repoPath(repo)+"/languages", nil, &languages); err != nil {
+		return "", errors.WithMessage(err, "couldn't find language")
+	}
+	return largestShare(languages), nil
+}
+
+// IssueStates requests the issues one by one
+func (s *giteaSource) IssueStates(ctx context.Context, repo RepositoryRef, numbers []int) (map[int]IssueState, error) {
+	return issueStatesOneByOne(numbers, func(number int) (bool, error) {
+		issue := giteaIssue{}
+		if _, err := s.get(ctx, repoPath(repo)+"/issues/"+strconv.Itoa(number), nil, &issue); err != nil {
+			return false, errors.WithMessage(err, "couldn't load issue from "+repo.Host+" "+repo.FullName())
+		}
+		return issue.State == "closed", nil
+	})
+}
+
+// ScopeSeparator separates the scope of the scoped labels, like "kind/bug"
+func (s *giteaSource) ScopeSeparator() string {
+	return "/"
+}
+
+// RateLimit is unknown, gitea doesn't report its quota
+func (s *giteaSource) RateLimit(ctx context.Context, repo RepositoryRef) (*RateLimit, error) {
+	return &RateLimit{Remaining: -1}, nil
+}
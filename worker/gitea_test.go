@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ossn/fixme_backend/models"
+)
+
+// Starts a stand-in for the gitea REST api that answers every path with the given response
+func newGiteaTestSource(t *testing.T, responses map[string]string) (*giteaSource, *httptest.Server) {
+	return newGiteaTestSourceWithHeaders(t, responses, nil)
+}
+
+// Starts a stand-in for the gitea REST api that also sets the given headers on the responses of the paths
+func newGiteaTestSourceWithHeaders(t *testing.T, responses map[string]string, headers map[string]http.Header) (*giteaSource, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token token" {
+			t.Errorf("expected the token to be sent")
+		}
+		if since := r.URL.Query().Get("since"); since != "" && since != "2023-01-01T00:00:00Z" {
+			t.Errorf("unexpected since %q", since)
+		}
+		response, exists := responses[r.URL.Path+"?"+r.URL.Query().Get("page")]
+		if !exists {
+			response, exists = responses[r.URL.Path]
+		}
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range headers[r.URL.Path+"?"+r.URL.Query().Get("page")] {
+			w.Header()[name] = values
+		}
+		w.Write([]byte(response))
+	}))
+	source := newGiteaSource(server.URL, "token")
+	source.httpClient = server.Client()
+	return source, server
+}
+
+func giteaIssuesJSON(from, count int) string {
+	issues := []string{}
+	for i := from; i < from+count; i++ {
+		issues = append(issues, fmt.Sprintf(`{"id":%d,"number":%d,"title":"Issue %d","state":"open","html_url":"https://codeberg.org/forgejo/forgejo/issues/%d",
+			"updated_at":"2023-01-02T15:04:05+01:00","labels":[{"name":"Kind/Bug"},{"name":"good first issue"}]}`, 1000+i, i, i, i))
+	}
+	return "[" + strings.Join(issues, ",") + "]"
+}
+
+func Test_GiteaSource_ListIssues(t *testing.T) {
+	source, server := newGiteaTestSource(t, map[string]string{
+		"/repos/forgejo/forgejo/issues?1": giteaIssuesJSON(1, giteaPageSize),
+		"/repos/forgejo/forgejo/issues?2": giteaIssuesJSON(giteaPageSize+1, 3),
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"codeberg.org", "forgejo", "forgejo"}
+
+	page, err := source.ListIssues(ctx, ref, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Issues) != giteaPageSize || page.NextCursor != "2" {
+		t.Fatalf("expected a full page with a next cursor, got %d issues and %q", len(page.Issues), page.NextCursor)
+	}
+
+	page, err = source.ListIssues(ctx, ref, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Issues) != 3 || page.NextCursor != "" {
+		t.Fatalf("expected the last page, got %d issues and %q", len(page.Issues), page.NextCursor)
+	}
+
+	issue := page.Issues[0]
+	if issue.ID != 1000+giteaPageSize+1 || issue.Closed || issue.UpdatedAt.IsZero() {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+
+	// Scoped labels are classified like the github ones
+	model := &models.Issue{}
+	applyLabels(model, issue.Labels, source.ScopeSeparator())
+	if model.ExperienceNeeded.String != "easy" || model.Type.String != "bugfix" {
+		t.Errorf("unexpected classification %q %q", model.ExperienceNeeded.String, model.Type.String)
+	}
+}
+
+func Test_GiteaSource_ListIssues_CappedPages(t *testing.T) {
+	// The instance caps the pages at 30 issues
+	source, server := newGiteaTestSourceWithHeaders(t, map[string]string{
+		"/repos/forgejo/forgejo/issues?1": giteaIssuesJSON(1, 30),
+		"/repos/forgejo/forgejo/issues?2": giteaIssuesJSON(31, 30),
+		"/repos/forgejo/forgejo/issues?3": giteaIssuesJSON(61, 5),
+	}, map[string]http.Header{
+		"/repos/forgejo/forgejo/issues?1": {"X-Total-Count": {"65"}, "Link": {`<https://codeberg.org/api/v1/repos/forgejo/forgejo/issues?page=2>; rel="next",<https://codeberg.org/api/v1/repos/forgejo/forgejo/issues?page=3>; rel="last"`}},
+		"/repos/forgejo/forgejo/issues?2": {"X-Total-Count": {"65"}},
+		"/repos/forgejo/forgejo/issues?3": {"X-Total-Count": {"65"}, "Link": {`<https://codeberg.org/api/v1/repos/forgejo/forgejo/issues?page=1>; rel="first"`}},
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"codeberg.org", "forgejo", "forgejo"}
+
+	count, cursor, pages := 0, "", 0
+	for {
+		page, err := source.ListIssues(ctx, ref, time.Time{}, cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += len(page.Issues)
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if count != 65 || pages != 3 {
+		t.Errorf("expected 65 issues in 3 pages, got %d issues in %d pages", count, pages)
+	}
+}
+
+func Test_GiteaSource_RepositoryData(t *testing.T) {
+	source, server := newGiteaTestSource(t, map[string]string{
+		"/repos/forgejo/forgejo/topics":    `{"topics":["forge","golang"]}`,
+		"/repos/forgejo/forgejo/languages": `{"Go":9876543,"JavaScript":123456}`,
+		"/repos/forgejo/forgejo/issues/3":  `{"id":3,"number":3,"state":"closed"}`,
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"codeberg.org", "forgejo", "forgejo"}
+
+	topics, err := source.Topics(ctx, ref)
+	if err != nil || len(topics) != 2 {
+		t.Errorf("unexpected topics %v %v", topics, err)
+	}
+	language, err := source.PrimaryLanguage(ctx, ref)
+	if err != nil || language != "Go" {
+		t.Errorf("unexpected language %q %v", language, err)
+	}
+	states, err := source.IssueStates(ctx, ref, []int{3})
+	if err != nil || states[3] != IssueStateClosed {
+		t.Errorf("expected the issue to be closed %v %v", states, err)
+	}
+}
@@ -0,0 +1,409 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shurcooL/githubv4"
+)
+
+type (
+	/**
+	* GraphQL Types
+	 */
+
+	PageInfo struct {
+		StartCursor     string
+		HasPreviousPage bool
+	}
+	Issues struct {
+		Nodes []struct {
+			Title      string
+			Body       string
+			Closed     bool
+			Number     int
+			URL        string
+			CreatedAt  string
+			UpdatedAt  string
+			DatabaseID int
+			Labels     struct {
+				Nodes []struct {
+					Name string
+				}
+			} `graphql:"labels(first:100)"`
+			Comments struct {
+				TotalCount int
+			}
+			Reactions struct {
+				TotalCount int
+			}
+		}
+		PageInfo PageInfo
+	}
+
+	language struct {
+		Repository struct {
+			PrimaryLanguage struct {
+				Name string
+			}
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	issueQuery struct {
+		Repository struct {
+			Issues Issues `graphql:"issues(last: 100, before: $before, filterBy: $filterBy)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	tagsQuery struct {
+		Repository struct {
+			RepositoryTopics struct {
+				Nodes []struct {
+					Topic struct {
+						Name string
+					}
+				}
+			} `graphql:"repositoryTopics(first: 100)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	repositoryInfoQuery struct {
+		Repository *struct {
+			URL        string
+			IsArchived bool
+			IsDisabled bool
+		} `graphql:"repository(owner: $owner, name: $name)"`
+	}
+
+	ownerRepositoriesQuery struct {
+		RepositoryOwner struct {
+			Login        string
+			Repositories struct {
+				Nodes []struct {
+					URL              string
+					IsArchived       bool
+					IsFork           bool
+					RepositoryTopics struct {
+						Nodes []struct {
+							Topic struct {
+								Name string
+							}
+						}
+					} `graphql:"repositoryTopics(first: 20)"`
+					Issues struct {
+						TotalCount int
+					} `graphql:"issues(states: OPEN, labels: $labels)"`
+				}
+				PageInfo struct {
+					EndCursor   string
+					HasNextPage bool
+				}
+			} `graphql:"repositories(first: 50, after: $after, isFork: false, orderBy: {field: NAME, direction: ASC})"`
+		} `graphql:"repositoryOwner(login: $login)"`
+	}
+
+	rateLimitQuery struct {
+		RateLimit struct {
+			Remaining int    `graphql:"remaining"`
+			ResetAt   string `graphql:"resetAt"`
+		} `graphql:"rateLimit"`
+	}
+
+	// githubSource loads issues through the github GraphQL API
+	githubSource struct {
+		client *githubv4.Client
+		// httpClient and endpoint send the queries that the client can't
+		httpClient *http.Client
+		endpoint   string
+		// tokens is nil when the requests are authenticated by the http client
+		tokens *tokenPool
+	}
+)
+
+// githubEndpoint is the url of the github GraphQL API
+const githubEndpoint = "https://api.github.com/graphql"
+
+func newGithubSourceAt(endpoint string, httpClient *http.Client) *githubSource {
+	return &githubSource{client: githubv4.NewEnterpriseClient(endpoint, httpClient), httpClient: httpClient, endpoint: endpoint}
+}
+
+// Creates a source that sends every request with the token of the pool that has the most remaining quota
+func newPooledGithubSource(endpoint string, tokens *tokenPool) *githubSource {
+	source := newGithubSourceAt(endpoint, &http.Client{Transport: tokens})
+	source.tokens = tokens
+	return source
+}
+
+func repositoryVariables(repo RepositoryRef) map[string]interface{} {
+	return map[string]interface{}{"name": githubv4.String(repo.Name), "owner": githubv4.String(repo.Owner)}
+}
+
+// ListIssues pages backwards through the issues of the repository that were updated after since
+func (s *githubSource) ListIssues(ctx context.Context, repo RepositoryRef, since time.Time, cursor string) (*IssuePage, error) {
+	ctx = withOwner(ctx, repo.Owner)
+	variables := repositoryVariables(repo)
+	variables["before"] = (*githubv4.String)(nil)
+	if cursor != "" {
+		variables["before"] = githubv4.NewString(githubv4.String(cursor))
+	}
+	filterBy := &githubv4.IssueFilters{}
+	if !since.IsZero() {
+		filterBy.Since = githubv4.NewDateTime(githubv4.DateTime{Time: since})
+	}
+	variables["filterBy"] = filterBy
+
+	issueData := issueQuery{}
+	if err := s.client.Query(ctx, &issueData, variables); err != nil {
+		return nil, errors.WithMessage(err, "couldn't load issues")
+	}
+
+	page := &IssuePage{}
+	for _, node := range issueData.Repository.Issues.Nodes {
+		labels := []string{}
+		for _, label := range node.Labels.Nodes {
+			labels = append(labels, label.Name)
+		}
+		page.Issues = append(page.Issues, SourceIssue{
+			ID:        node.DatabaseID,
+			Number:    node.Number,
+			Title:     node.Title,
+			Body:      node.Body,
+			Closed:    node.Closed,
+			URL:       node.URL,
+			CreatedAt: timeConvert(node.CreatedAt),
+			UpdatedAt: timeConvert(node.UpdatedAt),
+			Labels:    labels,
+			Comments:  node.Comments.TotalCount,
+			Reactions: node.Reactions.TotalCount,
+		})
+	}
+	if pageInfo := issueData.Repository.Issues.PageInfo; pageInfo.HasPreviousPage {
+		page.NextCursor = pageInfo.StartCursor
+	}
+	return page, nil
+}
+
+func (s *githubSource) Topics(ctx context.Context, repo RepositoryRef) ([]string, error) {
+	ctx = withOwner(ctx, repo.Owner)
+	tags := tagsQuery{}
+	if err := s.client.Query(ctx, &tags, repositoryVariables(repo)); err != nil {
+		return nil, errors.Wrap(err, "couldn't load repos from github")
+	}
+
+	topics := []string{}
+	for _, tag := range tags.Repository.RepositoryTopics.Nodes {
+		topics = append(topics, tag.Topic.Name)
+	}
+	return topics, nil
+}
+
+func (s *githubSource) PrimaryLanguage(ctx context.Context, repo RepositoryRef) (string, error) {
+	ctx = withOwner(ctx, repo.Owner)
+	languageRequest := language{}
+	if err := s.client.Query(ctx, &languageRequest, repositoryVariables(repo)); err != nil {
+		return "", errors.WithMessage(err, "couldn't find language")
+	}
+	return languageRequest.Repository.PrimaryLanguage.Name, nil
+}
+
+// IssueStates checks the issues with a single query, requesting every issue under an alias.
+// The GraphQL errors are decoded here because the client doesn't expose their type and path.
+func (s *githubSource) IssueStates(ctx context.Context, repo RepositoryRef, numbers []int) (map[int]IssueState, error) {
+	ctx = withOwner(ctx, repo.Owner)
+	states := map[int]IssueState{}
+	if len(numbers) == 0 {
+		return states, nil
+	}
+
+	query := strings.Builder{}
+	query.WriteString("query($owner: String!, $name: String!) { repository(owner: $owner, name: $name) { url")
+	for _, number := range numbers {
+		fmt.Fprintf(&query, " i%d: issue(number: %d) { closed url }", number, number)
+	}
+	query.WriteString(" } }")
+
+	response := struct {
+		Data struct {
+			Repository map[string]json.RawMessage `json:"repository"`
+		} `json:"data"`
+		Errors []graphqlError `json:"errors"`
+	}{}
+	if err := s.post(ctx, query.String(), repositoryVariables(repo), &response); err != nil {
+		return states, errors.WithMessage(err, "couldn't check the issues of "+repo.FullName())
+	}
+
+	var failure error
+	for _, graphqlErr := range response.Errors {
+		if graphqlErr.Type == "NOT_FOUND" && len(graphqlErr.Path) == 1 {
+			return states, ErrRepositoryNotFound
+		}
+		if number, ok := graphqlErr.issueNumber(); ok && graphqlErr.Type == "NOT_FOUND" {
+			states[number] = IssueStateMissing
+			continue
+		}
+		failure = errors.New("couldn't check the issues of " + repo.FullName() + ": " + graphqlErr.Message)
+	}
+
+	repositoryURL := ""
+	if err := json.Unmarshal(response.Data.Repository["url"], &repositoryURL); err != nil {
+		if failure == nil {
+			failure = errors.New("couldn't check the issues of " + repo.FullName() + ": the repository is missing from the response")
+		}
+		return states, failure
+	}
+	for _, number := range numbers {
+		issue := struct {
+			Closed bool   `json:"closed"`
+			URL    string `json:"url"`
+		}{}
+		raw, exists := response.Data.Repository["i"+strconv.Itoa(number)]
+		if !exists || string(raw) == "null" || json.Unmarshal(raw, &issue) != nil {
+			continue
+		}
+		switch {
+		// Transferred issues are resolved in the repository they were moved to
+		case !strings.HasPrefix(strings.ToLower(issue.URL), strings.ToLower(repositoryURL)+"/issues/"):
+			states[number] = IssueStateMissing
+		case issue.Closed:
+			states[number] = IssueStateClosed
+		default:
+			states[number] = IssueStateOpen
+		}
+	}
+	return states, failure
+}
+
+// graphqlError is an error of a github GraphQL response
+type graphqlError struct {
+	Type    string        `json:"type"`
+	Path    []interface{} `json:"path"`
+	Message string        `json:"message"`
+}
+
+// Returns the number of the issue an error is about, from the alias of the issue in its path
+func (e graphqlError) issueNumber() (int, bool) {
+	if len(e.Path) != 2 || e.Path[0] != "repository" {
+		return 0, false
+	}
+	alias, ok := e.Path[1].(string)
+	if !ok || !strings.HasPrefix(alias, "i") {
+		return 0, false
+	}
+	number, err := strconv.Atoi(alias[1:])
+	return number, err == nil
+}
+
+// Sends a raw GraphQL query and decodes the whole response to out, including its errors
+func (s *githubSource) post(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.New(fmt.Sprintf("github responded with %d", res.StatusCode))
+	}
+	return errors.WithMessage(json.NewDecoder(res.Body).Decode(out), "couldn't decode response")
+}
+
+// RepositoryInfo returns the state of a repository, github resolves the old names of renamed and transferred repositories
+func (s *githubSource) RepositoryInfo(ctx context.Context, repo RepositoryRef) (*RepositoryInfo, error) {
+	ctx = withOwner(ctx, repo.Owner)
+	query := repositoryInfoQuery{}
+	err := s.client.Query(ctx, &query, repositoryVariables(repo))
+	if err != nil && strings.HasPrefix(err.Error(), "Could not resolve to a Repository") {
+		return nil, ErrRepositoryNotFound
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't load repository "+repo.FullName())
+	}
+	if query.Repository == nil {
+		return nil, ErrRepositoryNotFound
+	}
+	return &RepositoryInfo{
+		URL:      query.Repository.URL,
+		Archived: query.Repository.IsArchived,
+		Disabled: query.Repository.IsDisabled,
+	}, nil
+}
+
+// OwnerRepositories pages through the repositories of an organization or a user, forks aren't listed
+func (s *githubSource) OwnerRepositories(ctx context.Context, owner string) ([]OwnerRepository, error) {
+	ctx = withOwner(ctx, owner)
+	labels := []githubv4.String{}
+	for _, label := range goodFirstIssueLabels {
+		labels = append(labels, githubv4.String(label))
+	}
+	variables := map[string]interface{}{
+		"login":  githubv4.String(owner),
+		"after":  (*githubv4.String)(nil),
+		"labels": labels,
+	}
+
+	repositories := []OwnerRepository{}
+	for {
+		query := ownerRepositoriesQuery{}
+		if err := s.client.Query(ctx, &query, variables); err != nil {
+			return nil, errors.WithMessage(err, "couldn't list the repositories of "+owner)
+		}
+		if query.RepositoryOwner.Login == "" {
+			return nil, errors.New("couldn't find the github organization or user " + owner)
+		}
+
+		for _, node := range query.RepositoryOwner.Repositories.Nodes {
+			topics := []string{}
+			for _, topic := range node.RepositoryTopics.Nodes {
+				topics = append(topics, topic.Topic.Name)
+			}
+			repositories = append(repositories, OwnerRepository{
+				URL:             node.URL,
+				Archived:        node.IsArchived,
+				Fork:            node.IsFork,
+				Topics:          topics,
+				GoodFirstIssues: node.Issues.TotalCount,
+			})
+		}
+
+		pageInfo := query.RepositoryOwner.Repositories.PageInfo
+		if !pageInfo.HasNextPage {
+			return repositories, nil
+		}
+		variables["after"] = githubv4.NewString(githubv4.String(pageInfo.EndCursor))
+	}
+}
+
+// RateLimit returns the quota left for the requests about a repository
+func (s *githubSource) RateLimit(ctx context.Context, repo RepositoryRef) (*RateLimit, error) {
+	// The quotas of the tokens the repository can use are known from their previous responses
+	if s.tokens != nil {
+		return s.tokens.rateLimit(repo.Owner)
+	}
+
+	rateLimitQuery := rateLimitQuery{}
+	if err := s.client.Query(ctx, &rateLimitQuery, nil); err != nil {
+		return nil, errors.WithMessage(err, "couldn't check the rate limit usage")
+	}
+	resetAt, err := time.Parse(time.RFC3339, rateLimitQuery.RateLimit.ResetAt)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't check the rate limit usage")
+	}
+	return &RateLimit{Remaining: rateLimitQuery.RateLimit.Remaining, ResetAt: resetAt}, nil
+}
@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// githubAPIURL is the url of the github REST API, the installation tokens are requested from it
+	githubAPIURL = "https://api.github.com"
+	// installationTokenMargin is how long before their expiry the installation tokens are replaced
+	installationTokenMargin = 5 * time.Minute
+	// installationsRefreshDelay is the minimum delay between two listings of the installations of the app
+	installationsRefreshDelay = 10 * time.Minute
+)
+
+type (
+	// githubApp authenticates as a github app, with a different token for every installation of the app
+	githubApp struct {
+		id         string
+		key        *rsa.PrivateKey
+		apiURL     string
+		httpClient *http.Client
+
+		mu sync.Mutex
+		// installations maps the lower cased logins of the accounts the app is installed on to the installation ids
+		installations map[string]int64
+		listedAt      time.Time
+	}
+
+	// installationTokenSource requests the tokens of an installation of a github app
+	installationTokenSource struct {
+		app            *githubApp
+		installationID int64
+	}
+
+	githubInstallation struct {
+		ID      int64 `json:"id"`
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+)
+
+func newGithubApp(apiURL, id string, keyPEM []byte, httpClient *http.Client) (*githubApp, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(keyPEM)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't read the github app private key")
+	}
+	return &githubApp{id: id, key: key, apiURL: strings.TrimSuffix(apiURL, "/"), httpClient: httpClient}, nil
+}
+
+// Reads the github app from GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_FILE, it returns nil when no app is configured
+func githubAppFromEnv() (*githubApp, error) {
+	id := os.Getenv("GITHUB_APP_ID")
+	keyFile := os.Getenv("GITHUB_APP_PRIVATE_KEY_FILE")
+	if id == "" && keyFile == "" {
+		return nil, nil
+	}
+	if id == "" || keyFile == "" {
+		return nil, errors.New("Please provide both GITHUB_APP_ID and GITHUB_APP_PRIVATE_KEY_FILE")
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't read the github app private key")
+	}
+	return newGithubApp(githubAPIURL, id, keyPEM, &http.Client{Timeout: 30 * time.Second})
+}
+
+// Signs the JWT the app authenticates with, it's backdated a bit in case of clock differences
+func (a *githubApp) jwt(now time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(9 * time.Minute).Unix(),
+		Issuer:    a.id,
+	})
+	signed, err := token.SignedString(a.key)
+	return signed, errors.WithMessage(err, "couldn't sign the github app jwt")
+}
+
+// Sends a request authenticated as the app and decodes its response
+func (a *githubApp) request(method, path string, out interface{}) error {
+	signed, err := a.jwt(time.Now())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, a.apiURL+path, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return errors.WithStack(&statusError{host: req.URL.Host, path: path, StatusCode: res.StatusCode})
+	}
+	return errors.WithMessage(json.NewDecoder(res.Body).Decode(out), "couldn't decode response")
+}
+
+// Returns the installation of the app on the account that owns a repository.
+// The installations are listed again when the owner is unknown, since the app might have been installed since.
+// A failed listing isn't tried again before the refresh delay either, the previous installations are kept meanwhile.
+func (a *githubApp) installationID(owner string) (int64, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	owner = strings.ToLower(owner)
+	if id, exists := a.installations[owner]; exists || time.Since(a.listedAt) < installationsRefreshDelay {
+		return id, exists, nil
+	}
+	a.listedAt = time.Now()
+
+	installations := map[string]int64{}
+	for page := 1; ; page++ {
+		pageInstallations := []githubInstallation{}
+		if err := a.request(http.MethodGet, fmt.Sprintf("/app/installations?per_page=100&page=%d", page), &pageInstallations); err != nil {
+			return 0, false, errors.WithMessage(err, "couldn't list the github app installations")
+		}
+		for _, installation := range pageInstallations {
+			installations[strings.ToLower(installation.Account.Login)] = installation.ID
+		}
+		if len(pageInstallations) < 100 {
+			break
+		}
+	}
+	a.installations = installations
+
+	id, exists := a.installations[owner]
+	return id, exists, nil
+}
+
+// Returns the source of the tokens of an installation, a new token is requested a bit before the previous one expires
+func (a *githubApp) tokenSource(installationID int64) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &installationTokenSource{app: a, installationID: installationID})
+}
+
+// Token requests a new installation token
+func (s *installationTokenSource) Token() (*oauth2.Token, error) {
+	response := struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{}
+	path := "/app/installations/" + strconv.FormatInt(s.installationID, 10) + "/access_tokens"
+	if err := s.app.request(http.MethodPost, path, &response); err != nil {
+		return nil, errors.WithMessage(err, "couldn't create a github installation token")
+	}
+	return &oauth2.Token{AccessToken: response.Token, TokenType: "token", Expiry: response.ExpiresAt.Add(-installationTokenMargin)}, nil
+}
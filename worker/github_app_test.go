@@ -0,0 +1,179 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+func newTestGithubApp(t *testing.T, apiURL string) (*githubApp, *rsa.PublicKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	app, err := newGithubApp(apiURL, "1234", keyPEM, http.DefaultClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, &key.PublicKey
+}
+
+// Reports whether a request is authenticated as the app
+func validAppJWT(r *http.Request, publicKey *rsa.PublicKey) bool {
+	claims := &jwt.StandardClaims{}
+	token, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		return publicKey, nil
+	})
+	return err == nil && token.Valid && claims.Issuer == "1234"
+}
+
+func Test_GithubApp_JWT(t *testing.T) {
+	app, publicKey := newTestGithubApp(t, "https://api.github.com")
+	signed, err := app.jwt(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("Authorization", "Bearer "+signed)
+	if !validAppJWT(req, publicKey) {
+		t.Error("expected the jwt to be signed by the app")
+	}
+	claims := &jwt.StandardClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(signed, claims); err != nil {
+		t.Fatal(err)
+	}
+	if lifetime := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; lifetime > 10*time.Minute {
+		t.Errorf("expected the jwt to expire within 10 minutes, got %s", lifetime)
+	}
+}
+
+func Test_TokenPool_GithubApp(t *testing.T) {
+	var publicKey *rsa.PublicKey
+	issued := 0
+	authorizations := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/app/installations":
+			if !validAppJWT(r, publicKey) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`[{"id": 7, "account": {"login": "Mozilla"}}]`))
+		case r.URL.Path == "/app/installations/7/access_tokens" && r.Method == http.MethodPost:
+			if !validAppJWT(r, publicKey) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			issued++
+			// The first token expires too soon to be used again
+			expiresAt := time.Now().Add(time.Hour)
+			if issued == 1 {
+				expiresAt = time.Now().Add(2 * time.Minute)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"token": fmt.Sprintf("installation-%d", issued), "expires_at": expiresAt})
+		case r.URL.Path == "/graphql":
+			authorizations = append(authorizations, r.Header.Get("Authorization"))
+			remaining := "4999"
+			if strings.HasPrefix(r.Header.Get("Authorization"), "Bearer") {
+				remaining = "150"
+			}
+			w.Header().Set("X-RateLimit-Remaining", remaining)
+			w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	app, key := newTestGithubApp(t, server.URL)
+	publicKey = key
+	pool := newTokenPool(http.DefaultTransport, app, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "personal"}))
+	client := &http.Client{Transport: pool}
+	post := func(owner string) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/graphql", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.Do(req.WithContext(withOwner(context.Background(), owner)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	// The repositories of the accounts the app isn't installed on fall back to the personal access token
+	post("ossn")
+	post("mozilla")
+	post("mozilla")
+	post("mozilla")
+	expected := []string{"Bearer personal", "token installation-1", "token installation-2", "token installation-2"}
+	if strings.Join(authorizations, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected the authorizations %v, got %v", expected, authorizations)
+	}
+	if issued != 2 {
+		t.Errorf("expected the expiring token to be replaced once, got %d tokens", issued)
+	}
+	// Only the repositories of the accounts the app is installed on can use the quota of the installation
+	if rateLimit, err := pool.rateLimit("mozilla"); err != nil || rateLimit.Remaining != 4999 {
+		t.Errorf("expected the quota of the installation to be available, got %+v %v", rateLimit, err)
+	}
+	if rateLimit, err := pool.rateLimit("ossn"); err != nil || rateLimit.Remaining != 150 {
+		t.Errorf("expected the quota of the personal access token, got %+v %v", rateLimit, err)
+	}
+}
+
+func Test_TokenPool_GithubAppNotInstalled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	app, _ := newTestGithubApp(t, server.URL)
+	client := &http.Client{Transport: newTokenPool(http.DefaultTransport, app)}
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/graphql", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req.WithContext(withOwner(context.Background(), "mozilla"))); err == nil {
+		t.Error("expected the request to fail without a token")
+	}
+}
+
+func Test_GithubApp_InstallationsFailure(t *testing.T) {
+	listings := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		listings++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	// The installations aren't listed again for every request while github fails
+	app, _ := newTestGithubApp(t, server.URL)
+	if _, _, err := app.installationID("mozilla"); err == nil {
+		t.Error("expected the listing to fail")
+	}
+	if _, installed, err := app.installationID("mozilla"); err != nil || installed {
+		t.Errorf("expected the owner to be unknown until the next listing, got %v %v", installed, err)
+	}
+	if listings != 1 {
+		t.Errorf("expected the installations to be listed once, got %d listings", listings)
+	}
+}
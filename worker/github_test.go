@@ -0,0 +1,253 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Starts a stand-in for the github GraphQL api that answers every query with the response of the first matching key
+func newGithubTestSource(t *testing.T, responses map[string]string) (*githubSource, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		if err := json.Unmarshal(body, &request); err != nil {
+			t.Fatal(err)
+		}
+		for key, response := range responses {
+			if strings.Contains(request.Query, key) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(response))
+				return
+			}
+		}
+		t.Errorf("unexpected query %s", request.Query)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	return newGithubSourceAt(server.URL, server.Client()), server
+}
+
+func Test_GithubSource_ListIssues(t *testing.T) {
+	source, server := newGithubTestSource(t, map[string]string{
+		"issues(last: 100": `{"data":{"repository":{"issues":{"nodes":[
+			{"title":"Fix typo","body":"","closed":false,"number":7,"url":"https://github.com/ossn/fixme/issues/7","updatedAt":"2019-08-01T10:00:00Z","databaseId":42,
+			 "labels":{"nodes":[{"name":"good first issue"}]}}],
+			"pageInfo":{"startCursor":"Y3Vyc29y","hasPreviousPage":true}}}}}`,
+	})
+	defer server.Close()
+
+	page, err := source.ListIssues(context.Background(), RepositoryRef{"github.com", "ossn", "fixme"}, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != "Y3Vyc29y" {
+		t.Errorf("expected the start cursor as the next cursor, got %q", page.NextCursor)
+	}
+	if len(page.Issues) != 1 {
+		t.Fatalf("expected 1 issue, got %d", len(page.Issues))
+	}
+	issue := page.Issues[0]
+	if issue.ID != 42 || issue.Number != 7 || issue.Labels[0] != "good first issue" || issue.UpdatedAt.IsZero() {
+		t.Errorf("unexpected issue %+v", issue)
+	}
+}
+
+func Test_GithubSource_RepositoryData(t *testing.T) {
+	source, server := newGithubTestSource(t, map[string]string{
+		"repositoryTopics": `{"data":{"repository":{"repositoryTopics":{"nodes":[{"topic":{"name":"webvr"}},{"topic":{"name":"threejs"}}]}}}}`,
+		"primaryLanguage":  `{"data":{"repository":{"primaryLanguage":{"name":"JavaScript"}}}}`,
+		"rateLimit":        `{"data":{"rateLimit":{"remaining":4999,"resetAt":"2019-08-01T11:00:00Z"}}}`,
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"github.com", "aframevr", "aframe"}
+
+	topics, err := source.Topics(ctx, ref)
+	if err != nil || len(topics) != 2 || topics[0] != "webvr" {
+		t.Errorf("unexpected topics %v %v", topics, err)
+	}
+	language, err := source.PrimaryLanguage(ctx, ref)
+	if err != nil || language != "JavaScript" {
+		t.Errorf("unexpected language %q %v", language, err)
+	}
+	rateLimit, err := source.RateLimit(ctx, ref)
+	if err != nil || rateLimit.Remaining != 4999 || rateLimit.ResetAt.IsZero() {
+		t.Errorf("unexpected rate limit %+v %v", rateLimit, err)
+	}
+}
+
+func Test_GithubSource_ListIssuesSince(t *testing.T) {
+	since := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Variables struct {
+				Before   *string
+				FilterBy struct {
+					Since string
+				}
+			}
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		if request.Variables.FilterBy.Since != "2019-08-01T10:00:00Z" || request.Variables.Before == nil || *request.Variables.Before != "Y3Vyc29y" {
+			t.Errorf("unexpected variables %+v", request.Variables)
+		}
+		w.Write([]byte(`{"data":{"repository":{"issues":{"nodes":[],"pageInfo":{"startCursor":"","hasPreviousPage":false}}}}}`))
+	}))
+	defer server.Close()
+	source := newGithubSourceAt(server.URL, server.Client())
+
+	page, err := source.ListIssues(context.Background(), RepositoryRef{"github.com", "ossn", "fixme"}, since, "Y3Vyc29y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Issues) != 0 || page.NextCursor != "" {
+		t.Errorf("expected an empty last page, got %+v", page)
+	}
+}
+
+func Test_GithubSource_OwnerRepositories(t *testing.T) {
+	pages := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if request.Variables["login"] == "missing" {
+			w.Write([]byte(`{"data":{"repositoryOwner":null}}`))
+			return
+		}
+		pages++
+		if request.Variables["after"] == nil {
+			w.Write([]byte(`{"data":{"repositoryOwner":{"login":"mozilla-mobile","repositories":{"nodes":[
+				{"url":"https://github.com/mozilla-mobile/fenix","isArchived":false,"isFork":false,
+				 "repositoryTopics":{"nodes":[{"topic":{"name":"android"}}]},"issues":{"totalCount":3}}],
+				"pageInfo":{"endCursor":"Y3Vyc29y","hasNextPage":true}}}}}`))
+			return
+		}
+		if request.Variables["after"] != "Y3Vyc29y" {
+			t.Errorf("unexpected cursor %v", request.Variables["after"])
+		}
+		w.Write([]byte(`{"data":{"repositoryOwner":{"login":"mozilla-mobile","repositories":{"nodes":[
+			{"url":"https://github.com/mozilla-mobile/focus-android","isArchived":true,"isFork":false,
+			 "repositoryTopics":{"nodes":[]},"issues":{"totalCount":0}}],
+			"pageInfo":{"endCursor":"","hasNextPage":false}}}}}`))
+	}))
+	defer server.Close()
+	source := newGithubSourceAt(server.URL, server.Client())
+
+	repositories, err := source.OwnerRepositories(context.Background(), "mozilla-mobile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages != 2 || len(repositories) != 2 {
+		t.Fatalf("expected 2 repositories on 2 pages, got %+v on %d", repositories, pages)
+	}
+	fenix := repositories[0]
+	if fenix.URL != "https://github.com/mozilla-mobile/fenix" || fenix.GoodFirstIssues != 3 || fenix.Topics[0] != "android" {
+		t.Errorf("unexpected repository %+v", fenix)
+	}
+	if !repositories[1].Archived {
+		t.Errorf("expected an archived repository, got %+v", repositories[1])
+	}
+
+	if _, err := source.OwnerRepositories(context.Background(), "missing"); err == nil {
+		t.Error("expected an error for a missing owner")
+	}
+}
+
+func Test_GithubSource_RepositoryInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Variables map[string]interface{} `json:"variables"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch request.Variables["name"] {
+		case "old-name":
+			w.Write([]byte(`{"data":{"repository":{"url":"https://github.com/new-owner/new-name","isArchived":true,"isDisabled":false}}}`))
+		case "missing":
+			w.Write([]byte(`{"data":{"repository":null},"errors":[{"type":"NOT_FOUND","path":["repository"],"message":"Could not resolve to a Repository with the name 'ossn/missing'."}]}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	source := newGithubSourceAt(server.URL, server.Client())
+	ctx := context.Background()
+
+	info, err := source.RepositoryInfo(ctx, RepositoryRef{"github.com", "ossn", "old-name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.URL != "https://github.com/new-owner/new-name" || !info.Archived || info.Disabled {
+		t.Errorf("unexpected repository info %+v", info)
+	}
+
+	if _, err := source.RepositoryInfo(ctx, RepositoryRef{"github.com", "ossn", "missing"}); err != ErrRepositoryNotFound {
+		t.Errorf("expected ErrRepositoryNotFound, got %v", err)
+	}
+
+	// Transport errors aren't mistaken for missing repositories
+	if _, err := source.RepositoryInfo(ctx, RepositoryRef{"github.com", "ossn", "flaky"}); err == nil || err == ErrRepositoryNotFound {
+		t.Errorf("expected a transport error, got %v", err)
+	}
+}
+
+func Test_GithubSource_IssueStates(t *testing.T) {
+	source, server := newGithubTestSource(t, map[string]string{
+		"i1: issue(number: 1)": `{"data":{"repository":{"url":"https://github.com/ossn/fixme",
+			"i1":{"closed":false,"url":"https://github.com/ossn/fixme/issues/1"},
+			"i2":{"closed":true,"url":"https://github.com/ossn/fixme/issues/2"},
+			"i3":null,
+			"i4":{"closed":false,"url":"https://github.com/ossn/fixme-backend/issues/9"},
+			"i5":null}},
+			"errors":[
+				{"type":"NOT_FOUND","path":["repository","i3"],"message":"Could not resolve to an Issue with the number of 3."},
+				{"type":"SERVICE_UNAVAILABLE","path":["repository","i5"],"message":"Something went wrong"}]}`,
+	})
+	defer server.Close()
+
+	states, err := source.IssueStates(context.Background(), RepositoryRef{"github.com", "ossn", "fixme"}, []int{1, 2, 3, 4, 5})
+	if err == nil {
+		t.Error("expected the failed issue to be reported")
+	}
+	expected := map[int]IssueState{1: IssueStateOpen, 2: IssueStateClosed, 3: IssueStateMissing, 4: IssueStateMissing}
+	if len(states) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, states)
+	}
+	for number, state := range expected {
+		if states[number] != state {
+			t.Errorf("expected issue %d to be %d, got %d", number, state, states[number])
+		}
+	}
+}
+
+func Test_GithubSource_IssueStates_TransportError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	states, err := newGithubSourceAt(server.URL, server.Client()).IssueStates(context.Background(), RepositoryRef{"github.com", "ossn", "fixme"}, []int{1})
+	if err == nil || len(states) != 0 {
+		t.Errorf("expected an error without states, got %v %v", states, err)
+	}
+}
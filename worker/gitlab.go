@@ -0,0 +1,162 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	/**
+	* GitLab REST v4 Types
+	 */
+
+	gitlabIssue struct {
+		ID          int      `json:"id"`
+		IID         int      `json:"iid"`
+		Title       string   `json:"title"`
+		Description string   `json:"description"`
+		State       string   `json:"state"`
+		WebURL      string   `json:"web_url"`
+		CreatedAt   string   `json:"created_at"`
+		UpdatedAt   string   `json:"updated_at"`
+		Labels      []string `json:"labels"`
+		// UserNotesCount is the number of comments
+		UserNotesCount int `json:"user_notes_count"`
+		Upvotes        int `json:"upvotes"`
+		Downvotes      int `json:"downvotes"`
+	}
+
+	gitlabProject struct {
+		Topics  []string `json:"topics"`
+		TagList []string `json:"tag_list"`
+	}
+
+	// gitlabSource loads issues through the GitLab REST v4 API
+	gitlabSource struct {
+		restClient
+
+		mu        sync.Mutex
+		rateLimit RateLimit
+	}
+)
+
+func newGitlabSource(baseURL, token string) *gitlabSource {
+	s := &gitlabSource{
+		restClient: newRestClient(baseURL, func(req *http.Request) {
+			if token != "" {
+				req.Header.Set("PRIVATE-TOKEN", token)
+			}
+		}),
+		rateLimit: RateLimit{Remaining: -1},
+	}
+	s.onResponse = s.trackRateLimit
+	return s
+}
+
+// Keeps the rate limit of the last response, gitlab doesn't have an endpoint for it
+func (s *gitlabSource) trackRateLimit(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.rateLimit = RateLimit{Remaining: remaining, ResetAt: time.Unix(reset, 0)}
+	s.mu.Unlock()
+}
+
+func projectPath(repo RepositoryRef) string {
+	return "/projects/" + url.PathEscape(repo.FullName())
+}
+
+// ListIssues pages through the issues of the project, using the page number as the cursor
+func (s *gitlabSource) ListIssues(ctx context.Context, repo RepositoryRef, since time.Time, cursor string) (*IssuePage, error) {
+	query := url.Values{}
+	query.Set("scope", "all")
+	if !since.IsZero() {
+		query.Set("updated_after", since.UTC().Format(time.RFC3339))
+	}
+	query.Set("order_by", "updated_at")
+	query.Set("sort", "desc")
+	query.Set("per_page", "100")
+	if cursor != "" {
+		query.Set("page", cursor)
+	}
+
+	gitlabIssues := []gitlabIssue{}
+	header, err := s.get(ctx, projectPath(repo)+"/issues", query, &gitlabIssues)
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't load issues")
+	}
+
+	page := &IssuePage{NextCursor: header.Get("X-Next-Page")}
+	for _, issue := range gitlabIssues {
+		page.Issues = append(page.Issues, SourceIssue{
+			ID:        issue.ID,
+			Number:    issue.IID,
+			Title:     issue.Title,
+			Body:      issue.Description,
+			Closed:    issue.State == "closed",
+			URL:       issue.WebURL,
+			CreatedAt: timeConvert(issue.CreatedAt),
+			UpdatedAt: timeConvert(issue.UpdatedAt),
+			Labels:    issue.Labels,
+			Comments:  issue.UserNotesCount,
+			Reactions: issue.Upvotes + issue.Downvotes,
+		})
+	}
+	return page, nil
+}
+
+func (s *gitlabSource) Topics(ctx context.Context, repo RepositoryRef) ([]string, error) {
+	project := gitlabProject{}
+	if _, err := s.get(ctx, projectPath(repo), nil, &project); err != nil {
+		return nil, errors.WithMessage(err, "couldn't load project")
+	}
+	// Older GitLab versions only expose tag_list
+	if len(project.Topics) == 0 {
+		return project.TagList, nil
+	}
+	return project.Topics, nil
+}
+
+// PrimaryLanguage returns the language with the biggest share in the project
+func (s *gitlabSource) PrimaryLanguage(ctx context.Context, repo RepositoryRef) (string, error) {
+	languages := map[string]float64{}
+	if _, err := s.get(ctx, projectPath(repo)+"/languages", nil, &languages); err != nil {
+		return "", errors.WithMessage(err, "couldn't find language")
+	}
+	return largestShare(languages), nil
+}
+
+// IssueStates requests the issues one by one, moved issues are closed by gitlab
+func (s *gitlabSource) IssueStates(ctx context.Context, repo RepositoryRef, numbers []int) (map[int]IssueState, error) {
+	return issueStatesOneByOne(numbers, func(number int) (bool, error) {
+		issue := gitlabIssue{}
+		if _, err := s.get(ctx, projectPath(repo)+"/issues/"+strconv.Itoa(number), nil, &issue); err != nil {
+			return false, errors.WithMessage(err, "couldn't load issue from gitlab "+repo.FullName())
+		}
+		return issue.State == "closed", nil
+	})
+}
+
+// ScopeSeparator separates the scope of the scoped labels, like "priority::high"
+func (s *gitlabSource) ScopeSeparator() string {
+	return "::"
+}
+
+func (s *gitlabSource) RateLimit(ctx context.Context, repo RepositoryRef) (*RateLimit, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rateLimit := s.rateLimit
+	return &rateLimit, nil
+}
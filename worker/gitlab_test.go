@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Starts a stand-in for the GitLab REST api that answers every escaped path with the given response
+func newGitlabTestSource(t *testing.T, responses map[string]string) (*gitlabSource, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			t.Errorf("expected the token to be sent")
+		}
+		response, exists := responses[r.URL.EscapedPath()]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if since := r.URL.Query().Get("updated_after"); since != "" && since != "2019-07-15T00:00:00Z" {
+			t.Errorf("unexpected updated_after %q", since)
+		}
+		w.Header().Set("RateLimit-Remaining", "1999")
+		w.Header().Set("RateLimit-Reset", "1564657200")
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		w.Write([]byte(response))
+	}))
+	source := newGitlabSource(server.URL, "token")
+	source.httpClient = server.Client()
+	return source, server
+}
+
+func Test_GitlabSource_ListIssues(t *testing.T) {
+	source, server := newGitlabTestSource(t, map[string]string{
+		"/projects/gitlab-org%2Fcharts%2Fgitlab/issues": `[
+			{"id":1001,"iid":3,"title":"Document values","description":"body","state":"opened","web_url":"https://gitlab.com/gitlab-org/charts/gitlab/issues/3",
+			 "updated_at":"2019-08-01T10:00:00.000Z","labels":["Accepting merge requests","documentation"]},
+			{"id":1002,"iid":4,"title":"Old","description":"","state":"closed","web_url":"https://gitlab.com/gitlab-org/charts/gitlab/issues/4",
+			 "updated_at":"2019-07-01T10:00:00.000Z","labels":[]}]`,
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"gitlab.com", "gitlab-org/charts", "gitlab"}
+
+	page, err := source.ListIssues(ctx, ref, time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.NextCursor != "2" {
+		t.Errorf("expected the next page as the cursor, got %q", page.NextCursor)
+	}
+	if len(page.Issues) != 2 || page.Issues[0].Number != 3 || page.Issues[0].Closed || !page.Issues[1].Closed {
+		t.Errorf("unexpected issues %+v", page.Issues)
+	}
+	if page.Issues[0].UpdatedAt.IsZero() {
+		t.Errorf("expected the update time to be parsed")
+	}
+
+	page, err = source.ListIssues(ctx, ref, time.Time{}, "2")
+	if err != nil || page.NextCursor != "" {
+		t.Errorf("expected the last page %+v %v", page, err)
+	}
+
+	since := time.Date(2019, 7, 15, 0, 0, 0, 0, time.UTC)
+	if _, err = source.ListIssues(ctx, ref, since, "2"); err != nil {
+		t.Fatal(err)
+	}
+
+	rateLimit, _ := source.RateLimit(ctx, ref)
+	if rateLimit.Remaining != 1999 {
+		t.Errorf("expected the rate limit to be tracked, got %+v", rateLimit)
+	}
+}
+
+func Test_GitlabSource_RepositoryData(t *testing.T) {
+	source, server := newGitlabTestSource(t, map[string]string{
+		"/projects/inkscape%2Finkscape":           `{"topics":[],"tag_list":["svg","vector-graphics"]}`,
+		"/projects/inkscape%2Finkscape/languages": `{"C++":86.5,"C":7.1,"Python":2.3}`,
+		"/projects/inkscape%2Finkscape/issues/12": `{"id":5,"iid":12,"state":"closed"}`,
+	})
+	defer server.Close()
+	ctx, ref := context.Background(), RepositoryRef{"gitlab.com", "inkscape", "inkscape"}
+
+	topics, err := source.Topics(ctx, ref)
+	if err != nil || len(topics) != 2 {
+		t.Errorf("unexpected topics %v %v", topics, err)
+	}
+	language, err := source.PrimaryLanguage(ctx, ref)
+	if err != nil || language != "C++" {
+		t.Errorf("unexpected language %q %v", language, err)
+	}
+	states, err := source.IssueStates(ctx, ref, []int{12, 13})
+	if err != nil || states[12] != IssueStateClosed {
+		t.Errorf("expected the issue to be closed %v %v", states, err)
+	}
+	if states[13] != IssueStateMissing {
+		t.Errorf("expected the issue to be missing %v", states)
+	}
+}
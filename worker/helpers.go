@@ -1,13 +1,10 @@
 package worker
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/gobuffalo/nulls"
 	"github.com/ossn/fixme_backend/models"
-	"github.com/pkg/errors"
-	"github.com/shurcooL/githubv4"
 )
 
 func split(r rune) bool {
@@ -27,16 +24,36 @@ func cleanupArray(s []string) (r []string) {
 	return
 }
 
-// Extracts the name and the owner from a git url
-func getNameAndOwner(url string) (githubv4.String, githubv4.String, error) {
+// Sets the labels of an issue and derives the experience needed and the type from them.
+// The scoped labels of the forges that have them, like "kind/bug" on gitea, are split with their scope separator.
+func applyLabels(model *models.Issue, labels []string, scopeSeparator string) {
+	model.ExperienceNeeded = nulls.String{}
+	model.Type = nulls.String{}
+	for i := range labels {
+		name := &labels[i]
+		// Search for known labels
+		matched := searchForMatchingLabels(name, model)
+		// Split name based on known delimeters
+		tmp := strings.FieldsFunc(*name, split)
+		// If label hasn't been matched try again with the splited string
+		if !matched && len(tmp) > 1 {
+			for _, label := range tmp {
+				searchForMatchingLabels(&label, model)
+			}
+		}
+		if !matched && scopeSeparator != "" && strings.Contains(*name, scopeSeparator) {
+			for _, label := range strings.Split(*name, scopeSeparator) {
+				label = strings.TrimSpace(label)
+				searchForMatchingLabels(&label, model)
+			}
+		}
+	}
 
-	tmp := strings.Split(strings.TrimSuffix(url, "/"), "/")
-	if len(tmp) < 2 {
-		err := errors.New(fmt.Sprintf("Couldn't find repo %s", url))
-		fmt.Println(errors.Wrap(err, "failed to find url"))
-		return githubv4.String(""), githubv4.String(""), err
+	model.Labels = labels
+	// Initialize experience needed with moderate
+	if !model.ExperienceNeeded.Valid {
+		model.ExperienceNeeded = nulls.String{String: "moderate", Valid: true}
 	}
-	return githubv4.String(tmp[len(tmp)-1]), githubv4.String(tmp[len(tmp)-2]), nil
 }
 
 // Searches if a label matches some known labels and updates the model
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/ossn/fixme_backend/models"
+)
+
+func Test_ApplyLabels(t *testing.T) {
+	tests := []struct {
+		name       string
+		labels     []string
+		experience string
+		issueType  string
+	}{
+		{"no labels", []string{}, "moderate", ""},
+		{"good first issue", []string{"good first issue"}, "easy", ""},
+		{"split label", []string{"type: bug", "Difficulty: Senior"}, "senior", "bugfix"},
+		{"enhancement", []string{"help wanted", "enhancement"}, "easy", "enhancement"},
+		{"slash", []string{"good first issue/easy"}, "moderate", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := &models.Issue{}
+			applyLabels(issue, tt.labels, "")
+			if issue.ExperienceNeeded.String != tt.experience {
+				t.Errorf("expected experience %q, got %q", tt.experience, issue.ExperienceNeeded.String)
+			}
+			if issue.Type.String != tt.issueType {
+				t.Errorf("expected type %q, got %q", tt.issueType, issue.Type.String)
+			}
+			if len(issue.Labels) != len(tt.labels) {
+				t.Errorf("expected %d labels, got %d", len(tt.labels), len(issue.Labels))
+			}
+		})
+	}
+}
+
+func Test_ApplyLabelsResetsClassification(t *testing.T) {
+	issue := &models.Issue{}
+	applyLabels(issue, []string{"easy", "bug"}, "")
+	applyLabels(issue, []string{"docs"}, "")
+	if issue.ExperienceNeeded.String != "moderate" || issue.Type.Valid {
+		t.Errorf("expected the old classification to be removed, got %q %q", issue.ExperienceNeeded.String, issue.Type.String)
+	}
+}
+
+func Test_ApplyScopedLabels(t *testing.T) {
+	tests := []struct {
+		name       string
+		labels     []string
+		separator  string
+		experience string
+		issueType  string
+	}{
+		{"gitea", []string{"Kind/Enhancement", "Difficulty/Easy"}, "/", "easy", "enhancement"},
+		{"gitlab", []string{"type::bug", "level::senior"}, "::", "senior", "bugfix"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := &models.Issue{}
+			applyLabels(issue, tt.labels, tt.separator)
+			if issue.ExperienceNeeded.String != tt.experience || issue.Type.String != tt.issueType {
+				t.Errorf("expected %q %q, got %q %q", tt.experience, tt.issueType, issue.ExperienceNeeded.String, issue.Type.String)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+// goodFirstIssueLabels are the labels the good first issues of the imported repositories are counted with
+var goodFirstIssueLabels = []string{"good first issue", "good-first-issue"}
+
+// Keeps the repositories that an import rule matches, archived repositories and forks are never imported
+func matchingRepositories(repositories []OwnerRepository, rule *models.RepositoryImport) []OwnerRepository {
+	matching := []OwnerRepository{}
+	for _, repository := range repositories {
+		if repository.Archived || repository.Fork || repository.GoodFirstIssues < rule.MinGoodFirstIssues {
+			continue
+		}
+		if rule.Topic.Valid && !containsFold(repository.Topics, rule.Topic.String) {
+			continue
+		}
+		matching = append(matching, repository)
+	}
+	return matching
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ImportRepositories attaches the repositories matched by an import rule to its project and marks the rule as checked.
+// It returns the created repositories, the ones that are already tracked by a project are skipped.
+func (w *Worker) ImportRepositories(tx *pop.Connection, rule *models.RepositoryImport) (models.Repositories, error) {
+	lister, ok := sources["github.com"].(RepositoryLister)
+	if !ok {
+		return nil, errors.New("the github source isn't configured")
+	}
+	ctx := w.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ownerRepositories, err := lister.OwnerRepositories(ctx, rule.Owner)
+	if err != nil {
+		return nil, err
+	}
+
+	created := models.Repositories{}
+	for _, ownerRepository := range matchingRepositories(ownerRepositories, rule) {
+		repositoryURL, err := NormalizeRepositoryURL(ownerRepository.URL)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		count, err := tx.Where("lower(trim(trailing '/' from repository_url)) = lower(?)", repositoryURL).Count(&models.Repository{})
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if count > 0 {
+			continue
+		}
+		repository := models.Repository{RepositoryUrl: repositoryURL, ProjectID: rule.ProjectID}
+		if err := tx.Create(&repository); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		created = append(created, repository)
+	}
+
+	rule.LastCheckedAt = nulls.NewTime(time.Now())
+	rule.LastError = nulls.String{}
+	if err := tx.Update(rule); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return created, nil
+}
+
+// Func to check the import rules periodically
+func (w *Worker) repositoryImportsPolling() {
+	interval := 6 * time.Hour
+	if value, err := time.ParseDuration(os.Getenv("REPOSITORY_IMPORT_INTERVAL")); err == nil && value > 0 {
+		interval = value
+	}
+	for {
+		w.checkRepositoryImports()
+		time.Sleep(interval)
+	}
+}
+
+// Imports the new repositories of every import rule, the failures are saved on the rules
+func (w *Worker) checkRepositoryImports() {
+	rules := models.RepositoryImports{}
+	if err := models.DB.Order("last_checked_at asc nulls first").All(&rules); err != nil {
+		fmt.Println(errors.WithMessage(err, "failed to get repository imports"))
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if err := w.waitUntilLimitIsRefreshed(w.ctx, sources["github.com"], RepositoryRef{Host: "github.com", Owner: rule.Owner}); err != nil {
+			return
+		}
+
+		var created models.Repositories
+		err := models.DB.Transaction(func(tx *pop.Connection) error {
+			var err error
+			created, err = w.ImportRepositories(tx, rule)
+			return err
+		})
+		if err != nil {
+			fmt.Println(errors.WithMessage(err, "repository import of "+rule.Owner+" failed"))
+			rule.LastCheckedAt = nulls.NewTime(time.Now())
+			rule.LastError = nulls.NewString(err.Error())
+			if err := models.DB.Update(rule); err != nil {
+				fmt.Println(errors.WithMessage(err, "failed to save repository import"))
+			}
+			continue
+		}
+
+		// The project detail lists the repositories of the project
+		if len(created) > 0 {
+			if _, err := cache.Backend.Invalidate(cache.ProjectTag(rule.ProjectID.String())); err != nil {
+				fmt.Println(errors.WithMessage(err, "cache invalidation failed"))
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/ossn/fixme_backend/models"
+)
+
+func Test_MatchingRepositories(t *testing.T) {
+	repositories := []OwnerRepository{
+		{URL: "https://github.com/mozilla-mobile/fenix", Topics: []string{"Android"}, GoodFirstIssues: 3},
+		{URL: "https://github.com/mozilla-mobile/firefox-ios", Topics: []string{"ios"}, GoodFirstIssues: 5},
+		{URL: "https://github.com/mozilla-mobile/focus-android", Topics: []string{"android"}, GoodFirstIssues: 4, Archived: true},
+		{URL: "https://github.com/mozilla-mobile/android-components", Topics: []string{"android"}, Fork: true},
+		{URL: "https://github.com/mozilla-mobile/docs"},
+	}
+
+	tests := []struct {
+		name     string
+		rule     models.RepositoryImport
+		expected []string
+	}{
+		{"all", models.RepositoryImport{}, []string{"fenix", "firefox-ios", "docs"}},
+		{"topic", models.RepositoryImport{Topic: nulls.NewString("android")}, []string{"fenix"}},
+		{"good first issues", models.RepositoryImport{MinGoodFirstIssues: 4}, []string{"firefox-ios"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matching := matchingRepositories(repositories, &tt.rule)
+			if len(matching) != len(tt.expected) {
+				t.Fatalf("expected %v, got %+v", tt.expected, matching)
+			}
+			for i, name := range tt.expected {
+				if matching[i].URL != "https://github.com/mozilla-mobile/"+name {
+					t.Errorf("expected %s, got %s", name, matching[i].URL)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/gobuffalo/pop/slices"
+	"github.com/ossn/fixme_backend/cache"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultJobMaxAttempts = 5
+	defaultJobRetryDelay  = time.Minute
+	// maxJobRetryDelay caps the exponential backoff of the failed jobs
+	maxJobRetryDelay      = 6 * time.Hour
+	defaultTopicsInterval = time.Hour
+)
+
+// Returns the delay before the next attempt of a job that failed a number of times in a row, it doubles on every failure
+func retryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxJobRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxJobRetryDelay {
+		delay = maxJobRetryDelay
+	}
+	return delay
+}
+
+// Runs a job and saves its outcome. The job might have been queued a while ago,
+// so it's skipped unless it can still be claimed, and it runs with the state saved in the database.
+func (w *Worker) runJob(job models.SyncJob) {
+	claimed, err := job.Claim(models.DB)
+	if err != nil {
+		fmt.Println(errors.WithMessage(err, "failed to start sync job"))
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	w.recordJobResult(&job, w.executeJob(job), time.Now())
+	if err := job.Finish(models.DB); err != nil {
+		fmt.Println(errors.WithMessage(err, "failed to save sync job"))
+	}
+}
+
+// Runs the task of a job, it's cancelled once it exceeds the job timeout
+func (w *Worker) executeJob(job models.SyncJob) error {
+	// The repository is loaded again since it might have changed while the job was queued
+	repository := models.Repository{}
+	if err := models.DB.Find(&repository, job.RepositoryID); err != nil {
+		return errors.WithMessage(err, "failed to find repository "+job.RepositoryID.String())
+	}
+	source, ref, err := sourceFor(repository.RepositoryUrl)
+	if err != nil {
+		return err
+	}
+
+	// The job is retried once the quota is reset rather than waiting for it within its timeout
+	if err := w.checkRateLimit(w.ctx, source, ref); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(w.ctx, w.syncJobTimeout)
+	defer cancel()
+
+	switch job.Kind {
+	case models.SyncJobKindSync:
+		return w.syncRepository(ctx, source, ref, &repository)
+	case models.SyncJobKindTopics:
+		return updateRepositoryTopics(ctx, source, ref, &repository)
+	case models.SyncJobKindDangling:
+		return w.searchForDanglingIssues(ctx, source, ref, &repository)
+	}
+	return errors.Errorf("unknown sync job kind %s", job.Kind)
+}
+
+// rateLimitedError is returned by the jobs that stopped because the quota of their source is exhausted
+type rateLimitedError struct {
+	resetAt time.Time
+}
+
+func (e *rateLimitedError) Error() string {
+	return "rate limited until " + e.resetAt.Format(time.RFC3339)
+}
+
+// Sets the next run of a job from its outcome. Failed jobs are retried with a growing delay until they are dead.
+func (w *Worker) recordJobResult(job *models.SyncJob, err error, now time.Time) {
+	// The rate limited jobs didn't fail, they run again once the quota is reset
+	if limited, ok := errors.Cause(err).(*rateLimitedError); ok {
+		job.Status = models.SyncJobStatusQueued
+		job.RunAt = limited.resetAt
+		if !job.RunAt.After(now) {
+			job.RunAt = now.Add(w.jobRetryDelay)
+		}
+		return
+	}
+
+	if err != nil {
+		fmt.Println(errors.WithMessage(err, job.Kind+" job of repository "+job.RepositoryID.String()+" failed"))
+		job.Attempts++
+		job.LastError = nulls.NewString(err.Error())
+		job.LastFailureAt = nulls.NewTime(now)
+		job.Status = models.SyncJobStatusQueued
+		job.RunAt = now.Add(retryDelay(w.jobRetryDelay, job.Attempts))
+		if job.Attempts >= w.jobMaxAttempts {
+			job.Status = models.SyncJobStatusDead
+		}
+		return
+	}
+
+	// The last error is kept so that the admins can still see why the previous attempts failed
+	job.Attempts = 0
+	job.LastSuccessAt = nulls.NewTime(now)
+	switch job.Kind {
+	case models.SyncJobKindSync:
+		// The repositories are synced one after the other
+		job.Status = models.SyncJobStatusQueued
+		job.RunAt = now
+	case models.SyncJobKindTopics:
+		job.Status = models.SyncJobStatusQueued
+		job.RunAt = now.Add(w.topicsInterval)
+	default:
+		job.Status = models.SyncJobStatusDone
+	}
+}
+
+// Queues the jobs that were running when the worker stopped again
+func requeueInterruptedJobs() error {
+	err := models.DB.RawQuery("update sync_jobs set status = ?, updated_at = ? where status = ?", models.SyncJobStatusQueued, time.Now(), models.SyncJobStatusRunning).Exec()
+	return errors.WithMessage(err, "failed to queue interrupted sync jobs")
+}
+
+// Refreshes the topics of a repository and the tags of its project
+func updateRepositoryTopics(ctx context.Context, source IssueSource, ref RepositoryRef, repository *models.Repository) error {
+	topics, err := source.Topics(ctx, ref)
+	if err != nil {
+		return err
+	}
+
+	// Only the tags are saved, so that the jobs of the other repositories of the project aren't overwritten
+	tags := slices.String(cleanupArray(topics))
+	if err := models.DB.RawQuery("update repositories set tags = ?, updated_at = ? where id = ?", tags, time.Now(), repository.ID).Exec(); err != nil {
+		return errors.WithMessage(err, "failed to save repository topics")
+	}
+
+	repositories := models.Repositories{}
+	if err := models.DB.Where("project_id = ?", repository.ProjectID).All(&repositories); err != nil {
+		return errors.WithMessage(err, "failed to find repos")
+	}
+	projectTags := []string{}
+	for _, projectRepository := range repositories {
+		projectTags = append(projectTags, projectRepository.Tags...)
+	}
+	if err := models.DB.RawQuery("update projects set tags = ?, updated_at = ? where id = ?", slices.String(cleanupArray(projectTags)), time.Now(), repository.ProjectID).Exec(); err != nil {
+		return errors.WithMessage(err, "failed to save project tags")
+	}
+
+	// The project detail shows the tags of the project and of its repositories
+	if _, err := cache.Backend.Invalidate(cache.ProjectTag(repository.ProjectID.String())); err != nil {
+		fmt.Println(errors.WithMessage(err, "cache invalidation failed"))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+func Test_RetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{20, maxJobRetryDelay},
+	}
+	for _, test := range tests {
+		if delay := retryDelay(time.Minute, test.attempts); delay != test.expected {
+			t.Errorf("expected a delay of %s after %d attempts, got %s", test.expected, test.attempts, delay)
+		}
+	}
+}
+
+func Test_Worker_RecordJobResult(t *testing.T) {
+	w := &Worker{jobMaxAttempts: 3, jobRetryDelay: time.Minute, topicsInterval: time.Hour}
+	now := time.Now()
+	job := &models.SyncJob{RepositoryID: uuid.Must(uuid.NewV4()), Kind: models.SyncJobKindSync, Status: models.SyncJobStatusRunning}
+
+	w.recordJobResult(job, errors.New("connection reset by peer"), now)
+	w.recordJobResult(job, errors.New("connection reset by peer"), now)
+	if job.Status != models.SyncJobStatusQueued || job.Attempts != 2 || !job.RunAt.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("expected the job to be retried in 2 minutes, got %s %d %s", job.Status, job.Attempts, job.RunAt.Sub(now))
+	}
+	if job.LastError.String != "connection reset by peer" || !job.LastFailureAt.Valid {
+		t.Errorf("expected the failure to be recorded, got %v", job.LastError)
+	}
+
+	w.recordJobResult(job, errors.New("connection reset by peer"), now)
+	if job.Status != models.SyncJobStatusDead {
+		t.Errorf("expected the job to be dead, got %s", job.Status)
+	}
+
+	w.recordJobResult(job, nil, now)
+	if job.Status != models.SyncJobStatusQueued || job.Attempts != 0 || !job.RunAt.Equal(now) || !job.LastSuccessAt.Valid {
+		t.Errorf("expected the sync to be queued again, got %s %d", job.Status, job.Attempts)
+	}
+
+	job.Kind = models.SyncJobKindTopics
+	w.recordJobResult(job, nil, now)
+	if !job.RunAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the topics to be refreshed in an hour, got %s", job.RunAt.Sub(now))
+	}
+
+	job.Kind = models.SyncJobKindDangling
+	w.recordJobResult(job, nil, now)
+	if job.Status != models.SyncJobStatusDone {
+		t.Errorf("expected the dangling issues check to be done, got %s", job.Status)
+	}
+}
+
+// exhaustedSource reports a quota under the minimum until a reset time
+type exhaustedSource struct {
+	IssueSource
+	resetAt time.Time
+}
+
+func (s *exhaustedSource) RateLimit(ctx context.Context, repo RepositoryRef) (*RateLimit, error) {
+	return &RateLimit{Remaining: 10, ResetAt: s.resetAt}, nil
+}
+
+func Test_Worker_RateLimitedJob(t *testing.T) {
+	w := &Worker{jobMaxAttempts: 3, jobRetryDelay: time.Minute}
+	source := &exhaustedSource{resetAt: time.Now().Add(30 * time.Minute).Truncate(time.Second)}
+	ref := RepositoryRef{"github.com", "ossn", "fixme"}
+
+	// The job runs again once the quota is reset, without counting as a failure
+	job := &models.SyncJob{RepositoryID: uuid.Must(uuid.NewV4()), Kind: models.SyncJobKindSync, Status: models.SyncJobStatusRunning, Attempts: 2}
+	w.recordJobResult(job, w.checkRateLimit(context.Background(), source, ref), time.Now())
+	if job.Status != models.SyncJobStatusQueued || job.Attempts != 2 || !job.RunAt.Equal(source.resetAt) || job.LastFailureAt.Valid {
+		t.Errorf("expected the job to be queued until the reset, got %s %d %s", job.Status, job.Attempts, job.RunAt)
+	}
+
+	// Waiting for the quota stops with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.waitUntilLimitIsRefreshed(ctx, source, ref); err != context.DeadlineExceeded {
+		t.Errorf("expected the wait to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+// Sets the status of a repository along with the message shown to the admins, it's saved by updateIssueCounts
+func setRepositoryStatus(repository *models.Repository, status, message string) {
+	repository.Status = status
+	repository.StatusMessage = nulls.String{String: message, Valid: message != ""}
+}
+
+// Closes the open issues of a repository that can't be worked on anymore
+func closeRepositoryIssues(repository *models.Repository) error {
+	err := models.DB.RawQuery("update issues set closed = true, updated_at = ? where repository_id = ? and closed = false", time.Now(), repository.ID).Exec()
+	return errors.WithMessage(err, "failed to close repository issues")
+}
+
+// Checks that a repository can still be synced, following renames and transfers.
+// Repositories that can't be synced are flagged and skipped until the next round, it returns false for them
+// along with the error when the check itself failed. Broken repositories are reported as errors too,
+// so that their jobs back off until they are dead instead of using up the quota on every round.
+func (w *Worker) checkRepository(ctx context.Context, inspector RepositoryInspector, repository *models.Repository, ref *RepositoryRef) (bool, error) {
+	previousStatus := repository.Status
+	info, err := inspector.RepositoryInfo(ctx, *ref)
+	switch {
+	case errors.Cause(err) == ErrRepositoryNotFound:
+		message := fmt.Sprintf("%s wasn't found on %s, it was deleted or made private", ref.FullName(), ref.Host)
+		setRepositoryStatus(repository, models.RepositoryStatusBroken, message)
+		w.skipRepository(repository, previousStatus)
+		return false, errors.New(message)
+	case err != nil:
+		// The repository is checked again on the next round
+		w.skipRepository(repository, previousStatus)
+		return false, err
+	case info.Archived || info.Disabled:
+		if err := closeRepositoryIssues(repository); err != nil {
+			fmt.Println(err)
+		}
+		message := "The repository is archived"
+		if info.Disabled {
+			message = "The repository is disabled"
+		}
+		setRepositoryStatus(repository, models.RepositoryStatusArchived, message)
+		w.skipRepository(repository, previousStatus)
+		return false, nil
+	}
+
+	currentURL, err := NormalizeRepositoryURL(info.URL)
+	if err != nil {
+		w.skipRepository(repository, previousStatus)
+		return false, err
+	}
+	trackedURL, err := NormalizeRepositoryURL(repository.RepositoryUrl)
+	if err != nil || !strings.EqualFold(currentURL, trackedURL) {
+		// Another repository of the same project or another one might track the new url already
+		count, err := models.DB.Where("lower(trim(trailing '/' from repository_url)) = lower(?) and id <> ?", currentURL, repository.ID).Count(&models.Repository{})
+		if err != nil {
+			w.skipRepository(repository, previousStatus)
+			return false, errors.WithMessage(err, "failed to find moved repository")
+		}
+		if count > 0 {
+			message := fmt.Sprintf("The repository moved to %s, which is already tracked", currentURL)
+			setRepositoryStatus(repository, models.RepositoryStatusBroken, message)
+			w.skipRepository(repository, previousStatus)
+			return false, errors.New(message)
+		}
+
+		setRepositoryStatus(repository, models.RepositoryStatusActive, fmt.Sprintf("The repository moved from %s", repository.RepositoryUrl))
+		repository.RepositoryUrl = currentURL
+		newRef, err := parseRepositoryURL(currentURL)
+		if err != nil {
+			w.skipRepository(repository, previousStatus)
+			return false, err
+		}
+		*ref = newRef
+		return true, nil
+	}
+
+	if previousStatus != models.RepositoryStatusActive {
+		setRepositoryStatus(repository, models.RepositoryStatusActive, "")
+	}
+	return true, nil
+}
+
+// Moves on to the next repository, the listings are refreshed when the repository stops being active
+func (w *Worker) skipRepository(repository *models.Repository, previousStatus string) {
+	repository.LastParsed = time.Now()
+	updateIssueCounts(repository)
+	if repository.Status != previousStatus {
+		go invalidateRepositoriesCache(repository)
+	}
+}
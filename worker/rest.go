@@ -0,0 +1,100 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// statusError is returned when an api responds with an unexpected status
+type statusError struct {
+	host       string
+	path       string
+	StatusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s responded with %d for %s", e.host, e.StatusCode, e.path)
+}
+
+// Reports whether an api responded that a resource doesn't exist
+func isNotFound(err error) bool {
+	statusErr, ok := errors.Cause(err).(*statusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
+
+// Checks the states of issues one request at a time, for the apis that can't check them in batches
+func issueStatesOneByOne(numbers []int, issueClosed func(number int) (bool, error)) (map[int]IssueState, error) {
+	states := map[int]IssueState{}
+	for _, number := range numbers {
+		closed, err := issueClosed(number)
+		switch {
+		case isNotFound(err):
+			states[number] = IssueStateMissing
+		case err != nil:
+			return states, err
+		case closed:
+			states[number] = IssueStateClosed
+		default:
+			states[number] = IssueStateOpen
+		}
+	}
+	return states, nil
+}
+
+// restClient sends the requests of the sources that use a REST api
+type restClient struct {
+	baseURL    string
+	httpClient *http.Client
+	// authorize adds the credentials to a request
+	authorize func(req *http.Request)
+	// onResponse inspects the headers of every response
+	onResponse func(header http.Header)
+}
+
+func newRestClient(baseURL string, authorize func(req *http.Request)) restClient {
+	return restClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: time.Minute},
+		authorize:  authorize,
+	}
+}
+
+// Sends a GET request to the api and decodes the response to out
+func (c *restClient) get(ctx context.Context, path string, query url.Values, out interface{}) (http.Header, error) {
+	requestURL := c.baseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if c.authorize != nil {
+		c.authorize(req)
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	if c.onResponse != nil {
+		c.onResponse(res.Header)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return res.Header, errors.WithStack(&statusError{host: req.URL.Host, path: path, StatusCode: res.StatusCode})
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return res.Header, errors.WithMessage(err, "couldn't decode response")
+	}
+	return res.Header, nil
+}
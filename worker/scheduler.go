@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSyncWorkers    = 4
+	defaultSyncQueueSize  = 16
+	defaultSyncJobTimeout = 10 * time.Minute
+	// dispatchIdleDelay is how long the dispatcher waits when there is no repository to queue
+	dispatchIdleDelay = 5 * time.Second
+)
+
+// scheduler runs the repository jobs on a fixed number of goroutines.
+// A repository is tracked from the moment one of its jobs is queued until the job is done,
+// so it's never synced by two goroutines at once.
+type scheduler struct {
+	workers int
+	jobs    chan models.SyncJob
+	// urgent jobs are run before the queued ones
+	urgent chan models.SyncJob
+	run    func(job models.SyncJob)
+
+	mu sync.Mutex
+	// queued and running map the repositories to their job
+	queued  map[uuid.UUID]models.SyncJob
+	running map[uuid.UUID]RunningJob
+}
+
+// RunningJob is a job along with the time a worker started it
+type RunningJob struct {
+	models.SyncJob
+	StartedAt time.Time `json:"started_at"`
+}
+
+func newScheduler(workers, queueSize int, run func(job models.SyncJob)) *scheduler {
+	return &scheduler{
+		workers: workers,
+		jobs:    make(chan models.SyncJob, queueSize),
+		urgent:  make(chan models.SyncJob, workers),
+		run:     run,
+		queued:  map[uuid.UUID]models.SyncJob{},
+		running: map[uuid.UUID]RunningJob{},
+	}
+}
+
+// Starts the goroutines that run the queued jobs
+func (s *scheduler) start() {
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for {
+				job := s.next()
+				s.begin(job)
+				s.run(job)
+				s.done(job.RepositoryID)
+			}
+		}()
+	}
+}
+
+// Waits for the next job, the urgent ones come first
+func (s *scheduler) next() models.SyncJob {
+	select {
+	case job := <-s.urgent:
+		return job
+	default:
+	}
+	select {
+	case job := <-s.urgent:
+		return job
+	case job := <-s.jobs:
+		return job
+	}
+}
+
+// Reserves the repository of a job, it returns false when a job of the repository is already queued or running
+func (s *scheduler) reserve(job models.SyncJob) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, queued := s.queued[job.RepositoryID]; queued {
+		return false
+	}
+	if _, running := s.running[job.RepositoryID]; running {
+		return false
+	}
+	s.queued[job.RepositoryID] = job
+	return true
+}
+
+func (s *scheduler) begin(job models.SyncJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queued, job.RepositoryID)
+	s.running[job.RepositoryID] = RunningJob{SyncJob: job, StartedAt: time.Now()}
+}
+
+// Releases a repository
+func (s *scheduler) done(repositoryID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queued, repositoryID)
+	delete(s.running, repositoryID)
+}
+
+// Queues a job, waiting for room in the queue.
+// It returns false when a job of the same repository is already queued or running.
+func (s *scheduler) enqueue(job models.SyncJob) bool {
+	if !s.reserve(job) {
+		return false
+	}
+	s.jobs <- job
+	return true
+}
+
+// Runs a job as soon as a worker is free, before the queued ones.
+// It returns false when a job of the same repository is already queued or running, or when there are too many urgent jobs.
+func (s *scheduler) enqueueUrgent(job models.SyncJob) bool {
+	if !s.reserve(job) {
+		return false
+	}
+	select {
+	case s.urgent <- job:
+		return true
+	default:
+		s.done(job.RepositoryID)
+		return false
+	}
+}
+
+// Returns the running jobs, the longest running first, and the queued jobs by run time
+func (s *scheduler) state() ([]RunningJob, []models.SyncJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	running := make([]RunningJob, 0, len(s.running))
+	for _, job := range s.running {
+		running = append(running, job)
+	}
+	queued := make([]models.SyncJob, 0, len(s.queued))
+	for _, job := range s.queued {
+		queued = append(queued, job)
+	}
+	sort.Slice(running, func(i, j int) bool { return running[i].StartedAt.Before(running[j].StartedAt) })
+	sort.Slice(queued, func(i, j int) bool { return queued[i].RunAt.Before(queued[j].RunAt) })
+	return running, queued
+}
+
+// Queues the jobs that are due, forever
+func (s *scheduler) dispatch() {
+	for {
+		// The repositories added since the last round get their jobs
+		for _, kind := range []string{models.SyncJobKindSync, models.SyncJobKindTopics} {
+			if err := models.CreateMissingSyncJobs(models.DB, kind); err != nil {
+				fmt.Println(errors.WithMessage(err, "failed to create "+kind+" jobs"))
+			}
+		}
+
+		// Enough candidates to fill the queue even when all the workers are busy with some of them
+		jobs := models.SyncJobs{}
+		err := models.DB.Where("status = ? and run_at <= ?", models.SyncJobStatusQueued, time.Now()).
+			Order("run_at asc").Limit(s.workers + cap(s.jobs) + 1).All(&jobs)
+		if err != nil {
+			fmt.Println(errors.WithMessage(err, "failed to get sync jobs"))
+		}
+
+		queued := 0
+		for _, job := range jobs {
+			if s.enqueue(job) {
+				queued++
+			}
+		}
+		if queued == 0 {
+			time.Sleep(dispatchIdleDelay)
+		}
+	}
+}
+
+// Reads a positive integer from the environment
+func envInt(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// Reads a positive duration from the environment
+func envDuration(name string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+)
+
+func Test_Scheduler_BoundsConcurrency(t *testing.T) {
+	var running, maxRunning int32
+	var wg sync.WaitGroup
+	s := newScheduler(2, 3, func(job models.SyncJob) {
+		defer wg.Done()
+		current := atomic.AddInt32(&running, 1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+	})
+	s.start()
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		if !s.enqueue(models.SyncJob{ID: uuid.Must(uuid.NewV4()), RepositoryID: uuid.Must(uuid.NewV4())}) {
+			t.Fatal("expected the job of a new repository to be queued")
+		}
+	}
+	wg.Wait()
+
+	if maxRunning != 2 {
+		t.Errorf("expected 2 concurrent jobs, got %d", maxRunning)
+	}
+}
+
+func Test_Scheduler_SingleJobPerRepository(t *testing.T) {
+	release := make(chan bool)
+	finished := make(chan models.SyncJob)
+	s := newScheduler(2, 2, func(job models.SyncJob) {
+		<-release
+		finished <- job
+	})
+	s.start()
+
+	repositoryID := uuid.Must(uuid.NewV4())
+	if !s.enqueue(models.SyncJob{ID: uuid.Must(uuid.NewV4()), RepositoryID: repositoryID, Kind: models.SyncJobKindSync}) {
+		t.Fatal("expected the job to be queued")
+	}
+	if s.enqueue(models.SyncJob{ID: uuid.Must(uuid.NewV4()), RepositoryID: repositoryID, Kind: models.SyncJobKindDangling}) {
+		t.Error("expected the jobs of a running repository not to be queued")
+	}
+
+	release <- true
+	<-finished
+	// The repository is released once its job is done
+	deadline := time.Now().Add(time.Second)
+	for !s.reserve(models.SyncJob{RepositoryID: repositoryID}) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the repository to be released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
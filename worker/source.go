@@ -0,0 +1,232 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type (
+	// RepositoryRef identifies a repository on the forge that hosts it
+	RepositoryRef struct {
+		Host  string
+		Owner string
+		Name  string
+	}
+
+	// SourceIssue is an issue as returned by an IssueSource
+	SourceIssue struct {
+		// ID is the forge wide id of the issue
+		ID        int
+		Number    int
+		Title     string
+		Body      string
+		Closed    bool
+		URL       string
+		CreatedAt time.Time
+		UpdatedAt time.Time
+		Labels    []string
+		Comments  int
+		Reactions int
+	}
+
+	// IssuePage is a page of issues, NextCursor is empty on the last page
+	IssuePage struct {
+		Issues     []SourceIssue
+		NextCursor string
+	}
+
+	// IssueState is the state of an issue on its forge
+	IssueState int
+
+	// RateLimit is the remaining quota of an IssueSource, a negative Remaining means that it's unknown
+	RateLimit struct {
+		Remaining int
+		ResetAt   time.Time
+	}
+
+	// OwnerRepository is a repository of an organization or a user
+	OwnerRepository struct {
+		URL      string
+		Archived bool
+		Fork     bool
+		Topics   []string
+		// GoodFirstIssues is the number of open issues with one of the goodFirstIssueLabels
+		GoodFirstIssues int
+	}
+
+	// RepositoryLister is implemented by the sources that can list the repositories of an organization or a user
+	RepositoryLister interface {
+		OwnerRepositories(ctx context.Context, owner string) ([]OwnerRepository, error)
+	}
+
+	// RepositoryInfo is the state of a repository on its forge
+	RepositoryInfo struct {
+		// URL is the current url of the repository, it differs from the requested one after a rename or a transfer
+		URL      string
+		Archived bool
+		Disabled bool
+	}
+
+	// LabelScoper is implemented by the sources whose labels can be scoped, ScopeSeparator separates the scope from the value
+	LabelScoper interface {
+		ScopeSeparator() string
+	}
+
+	// RepositoryInspector is implemented by the sources that can tell whether a repository moved or can't be worked on anymore.
+	// RepositoryInfo returns ErrRepositoryNotFound when the forge doesn't have the repository.
+	RepositoryInspector interface {
+		RepositoryInfo(ctx context.Context, repo RepositoryRef) (*RepositoryInfo, error)
+	}
+
+	// IssueSource is a forge that issues, topics and languages of repositories are loaded from
+	IssueSource interface {
+		// ListIssues returns a page of the issues that were updated after since, a zero since lists all of them
+		ListIssues(ctx context.Context, repo RepositoryRef, since time.Time, cursor string) (*IssuePage, error)
+		Topics(ctx context.Context, repo RepositoryRef) ([]string, error)
+		PrimaryLanguage(ctx context.Context, repo RepositoryRef) (string, error)
+		// IssueStates returns the states of issues. On errors the states that are known are still returned,
+		// the issues that are missing from them must not be considered as closed.
+		IssueStates(ctx context.Context, repo RepositoryRef, numbers []int) (map[int]IssueState, error)
+		// RateLimit returns the quota left for the requests about a repository
+		RateLimit(ctx context.Context, repo RepositoryRef) (*RateLimit, error)
+	}
+)
+
+const (
+	IssueStateOpen IssueState = iota
+	IssueStateClosed
+	// IssueStateMissing issues were deleted or transferred to another repository
+	IssueStateMissing
+)
+
+// ErrRepositoryNotFound is returned when a forge doesn't have a repository, or it's private
+var ErrRepositoryNotFound = errors.New("repository not found")
+
+// sources maps a forge host to the source its repositories are loaded from
+var sources = map[string]IssueSource{}
+
+// FullName returns the owner/name path of the repository
+func (r RepositoryRef) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// Extracts the host, the owner and the name from a git url.
+// The owner can contain multiple path segments for forges with nested groups.
+func parseRepositoryURL(repositoryURL string) (RepositoryRef, error) {
+	u, err := url.Parse(strings.TrimSpace(repositoryURL))
+	if err != nil || u.Host == "" {
+		return RepositoryRef{}, errors.New(fmt.Sprintf("Couldn't find repo %s", repositoryURL))
+	}
+
+	path := strings.Trim(strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git"), "/")
+	tmp := strings.Split(path, "/")
+	if len(tmp) < 2 {
+		return RepositoryRef{}, errors.New(fmt.Sprintf("Couldn't find repo %s", repositoryURL))
+	}
+
+	return RepositoryRef{
+		Host:  strings.TrimPrefix(strings.ToLower(u.Host), "www."),
+		Owner: strings.Join(tmp[:len(tmp)-1], "/"),
+		Name:  tmp[len(tmp)-1],
+	}, nil
+}
+
+// Finds the source that serves a repository url
+func sourceFor(repositoryURL string) (IssueSource, RepositoryRef, error) {
+	ref, err := parseRepositoryURL(repositoryURL)
+	if err != nil {
+		return nil, ref, err
+	}
+	source, exists := sources[ref.Host]
+	if !exists {
+		return nil, ref, errors.New(fmt.Sprintf("%s isn't a supported host", ref.Host))
+	}
+	return source, ref, nil
+}
+
+// Returns the language with the biggest share, ties are broken alphabetically
+func largestShare(languages map[string]float64) string {
+	primaryLanguage, share := "", 0.0
+	for name, value := range languages {
+		if value > share || (value == share && name < primaryLanguage) {
+			primaryLanguage, share = name, value
+		}
+	}
+	return primaryLanguage
+}
+
+// Parses a comma separated list of host=token pairs, the token is optional
+func parseHostTokens(value string) map[string]string {
+	hostTokens := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		tmp := strings.SplitN(pair, "=", 2)
+		host := strings.ToLower(strings.TrimSpace(tmp[0]))
+		token := ""
+		if len(tmp) > 1 {
+			token = strings.TrimSpace(tmp[1])
+		}
+		hostTokens[host] = token
+	}
+	return hostTokens
+}
+
+// Returns the gitlab hosts along with their tokens, gitlab.com is always one of them
+func gitlabHosts() map[string]string {
+	hosts := parseHostTokens(os.Getenv("GITLAB_HOSTS"))
+	if _, exists := hosts["gitlab.com"]; !exists {
+		hosts["gitlab.com"] = os.Getenv("GITLAB_TOKEN")
+	}
+	return hosts
+}
+
+// Returns the gitea hosts along with their tokens, codeberg.org is always one of them
+func giteaHosts() map[string]string {
+	hosts := parseHostTokens(os.Getenv("GITEA_HOSTS"))
+	if _, exists := hosts["codeberg.org"]; !exists {
+		hosts["codeberg.org"] = os.Getenv("CODEBERG_TOKEN")
+	}
+	return hosts
+}
+
+// Register the sources of the forges other than github
+func registerSources() {
+	for host, token := range gitlabHosts() {
+		sources[host] = newGitlabSource("https://"+host+"/api/v4", token)
+	}
+
+	for host, token := range giteaHosts() {
+		sources[host] = newGiteaSource("https://"+host+"/api/v1", token)
+	}
+}
+
+// NormalizeRepositoryURL checks that a url points at a repository of a supported forge and returns
+// its canonical form, so that the same repository is always stored with the same url
+func NormalizeRepositoryURL(repositoryURL string) (string, error) {
+	ref, err := parseRepositoryURL(repositoryURL)
+	if err != nil {
+		return "", err
+	}
+	_, isGitlab := gitlabHosts()[ref.Host]
+	_, isGitea := giteaHosts()[ref.Host]
+	switch {
+	case isGitlab:
+	case ref.Host == "github.com" || isGitea:
+		// Only gitlab has nested groups
+		if strings.Contains(ref.Owner, "/") {
+			return "", errors.New(fmt.Sprintf("%s isn't a repository url", repositoryURL))
+		}
+	default:
+		return "", errors.New(fmt.Sprintf("%s isn't a supported host", ref.Host))
+	}
+	return "https://" + ref.Host + "/" + ref.FullName(), nil
+}
@@ -0,0 +1,81 @@
+package worker
+
+import "testing"
+
+func Test_ParseRepositoryURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected RepositoryRef
+		fails    bool
+	}{
+		{url: "https://github.com/aframevr/aframe/", expected: RepositoryRef{"github.com", "aframevr", "aframe"}},
+		{url: "https://www.github.com/mozilla/voice-web.git", expected: RepositoryRef{"github.com", "mozilla", "voice-web"}},
+		{url: "https://gitlab.com/gitlab-org/charts/gitlab", expected: RepositoryRef{"gitlab.com", "gitlab-org/charts", "gitlab"}},
+		{url: "https://voice.mozilla.org/en", fails: true},
+		{url: "github.com/ossn/fixme_backend", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			ref, err := parseRepositoryURL(tt.url)
+			if tt.fails {
+				if err == nil {
+					t.Errorf("expected an error, got %+v", ref)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ref != tt.expected {
+				t.Errorf("expected %+v, got %+v", tt.expected, ref)
+			}
+		})
+	}
+}
+
+func Test_ParseHostTokens(t *testing.T) {
+	hostTokens := parseHostTokens("gitlab.example.com=secret, GitLab.GNOME.org ,")
+	if len(hostTokens) != 2 {
+		t.Fatalf("expected 2 hosts, got %v", hostTokens)
+	}
+	if hostTokens["gitlab.example.com"] != "secret" {
+		t.Errorf("expected the token to be parsed, got %q", hostTokens["gitlab.example.com"])
+	}
+	if token, exists := hostTokens["gitlab.gnome.org"]; !exists || token != "" {
+		t.Errorf("expected a host without a token, got %v", hostTokens)
+	}
+}
+
+func Test_NormalizeRepositoryURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+		fails    bool
+	}{
+		{url: "https://www.github.com/mozilla/voice-web.git", expected: "https://github.com/mozilla/voice-web"},
+		{url: " https://gitlab.com/gitlab-org/charts/gitlab/ ", expected: "https://gitlab.com/gitlab-org/charts/gitlab"},
+		{url: "https://codeberg.org/forgejo/forgejo", expected: "https://codeberg.org/forgejo/forgejo"},
+		{url: "https://github.com/mozilla/voice-web/issues", fails: true},
+		{url: "https://voice.mozilla.org/en/speak", fails: true},
+		{url: "https://voice.mozilla.org", fails: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			normalized, err := NormalizeRepositoryURL(tt.url)
+			if tt.fails {
+				if err == nil {
+					t.Errorf("expected an error, got %s", normalized)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if normalized != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, normalized)
+			}
+		})
+	}
+}
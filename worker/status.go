@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ossn/fixme_backend/models"
+)
+
+type (
+	// RateLimitStatus is the quota a source reported the last time the worker checked it
+	RateLimitStatus struct {
+		Host string `json:"host"`
+		// Remaining is negative when the source doesn't report its quota
+		Remaining int       `json:"remaining"`
+		ResetAt   time.Time `json:"reset_at"`
+		// Limited is true while the worker waits for the quota to be reset
+		Limited   bool      `json:"limited"`
+		CheckedAt time.Time `json:"checked_at"`
+	}
+
+	// Status is what the worker is doing
+	Status struct {
+		// Started is false when the polling doesn't run in this process
+		Started     bool              `json:"started"`
+		Workers     int               `json:"workers"`
+		QueueSize   int               `json:"queue_size"`
+		RunningJobs []RunningJob      `json:"running_jobs"`
+		QueuedJobs  []models.SyncJob  `json:"queued_jobs"`
+		RateLimits  []RateLimitStatus `json:"rate_limits"`
+	}
+)
+
+// Saves the quota a source reported
+func (w *Worker) recordRateLimit(source IssueSource, rateLimit *RateLimit, limited bool) {
+	for host, hostSource := range sources {
+		if hostSource != source {
+			continue
+		}
+		w.mu.Lock()
+		if w.rateLimits == nil {
+			w.rateLimits = map[string]RateLimitStatus{}
+		}
+		w.rateLimits[host] = RateLimitStatus{Host: host, Remaining: rateLimit.Remaining, ResetAt: rateLimit.ResetAt, Limited: limited, CheckedAt: time.Now()}
+		w.mu.Unlock()
+	}
+}
+
+// Status returns the running and queued jobs along with the quotas of the sources
+func (w *Worker) Status() Status {
+	status := Status{RunningJobs: []RunningJob{}, QueuedJobs: []models.SyncJob{}, RateLimits: []RateLimitStatus{}}
+	if w.scheduler != nil {
+		status.Started = true
+		status.Workers = w.scheduler.workers
+		status.QueueSize = cap(w.scheduler.jobs)
+		status.RunningJobs, status.QueuedJobs = w.scheduler.state()
+	}
+
+	w.mu.Lock()
+	for _, rateLimit := range w.rateLimits {
+		status.RateLimits = append(status.RateLimits, rateLimit)
+	}
+	w.mu.Unlock()
+	sort.Slice(status.RateLimits, func(i, j int) bool { return status.RateLimits[i].Host < status.RateLimits[j].Host })
+	return status
+}
+
+// SyncNow runs a job before the queued ones as soon as a worker is free.
+// It returns false when the worker doesn't run in this process or is already busy with the repository.
+func (w *Worker) SyncNow(job models.SyncJob) bool {
+	if w.scheduler == nil {
+		return false
+	}
+	return w.scheduler.enqueueUrgent(job)
+}
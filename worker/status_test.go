@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/ossn/fixme_backend/models"
+)
+
+func Test_Worker_Status(t *testing.T) {
+	w := &Worker{}
+	if status := w.Status(); status.Started || w.SyncNow(models.SyncJob{RepositoryID: uuid.Must(uuid.NewV4())}) {
+		t.Error("expected the worker not to run")
+	}
+
+	// The workers aren't started so that the jobs stay queued
+	w.scheduler = newScheduler(1, 2, func(job models.SyncJob) {})
+	job := models.SyncJob{ID: uuid.Must(uuid.NewV4()), RepositoryID: uuid.Must(uuid.NewV4()), Kind: models.SyncJobKindSync}
+	if !w.SyncNow(job) {
+		t.Fatal("expected the job to be queued")
+	}
+	if w.SyncNow(job) {
+		t.Error("expected the queued repository not to be queued again")
+	}
+
+	source := &githubSource{}
+	defer func(previous IssueSource) { sources["github.com"] = previous }(sources["github.com"])
+	sources["github.com"] = source
+	w.recordRateLimit(source, &RateLimit{Remaining: 42, ResetAt: time.Now().Add(time.Hour)}, false)
+
+	status := w.Status()
+	if !status.Started || status.Workers != 1 || status.QueueSize != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(status.QueuedJobs) != 1 || status.QueuedJobs[0].ID != job.ID || len(status.RunningJobs) != 0 {
+		t.Errorf("expected the job to be queued, got %+v", status)
+	}
+	if len(status.RateLimits) != 1 || status.RateLimits[0].Host != "github.com" || status.RateLimits[0].Remaining != 42 {
+		t.Errorf("unexpected rate limits %+v", status.RateLimits)
+	}
+
+	// The urgent job runs before the queued ones
+	w.scheduler.jobs <- models.SyncJob{ID: uuid.Must(uuid.NewV4())}
+	if next := w.scheduler.next(); next.ID != job.ID {
+		t.Errorf("expected the urgent job to run first, got %v", next.ID)
+	}
+}
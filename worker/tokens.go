@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// minRemainingQuota is the quota under which the worker waits for the rate limit to be reset
+const minRemainingQuota = 100
+
+type (
+	// tokenPool authenticates the requests to github with the token that has the most remaining quota.
+	// The quota of every token is tracked from the rate limit headers of its responses.
+	// When a github app is configured, the requests for the repositories of the accounts it's installed on
+	// can use the token of the installation as well, the other requests fall back to the personal access tokens.
+	tokenPool struct {
+		base   http.RoundTripper
+		app    *githubApp
+		mu     sync.Mutex
+		tokens []*pooledToken
+		// installations are the tokens of the installations of the app by installation id
+		installations map[int64]*pooledToken
+	}
+
+	pooledToken struct {
+		source oauth2.TokenSource
+		// remaining is negative until a response reports the quota of the token
+		remaining int
+		resetAt   time.Time
+	}
+)
+
+// ownerKey is the context key of the owner of the repository a request is about
+type ownerKey struct{}
+
+// Sets the owner of the repository the requests sent with a context are about, so that the installation of the github app is used
+func withOwner(ctx context.Context, owner string) context.Context {
+	return context.WithValue(ctx, ownerKey{}, owner)
+}
+
+func newTokenPool(base http.RoundTripper, app *githubApp, sources ...oauth2.TokenSource) *tokenPool {
+	pool := &tokenPool{base: base, app: app, installations: map[int64]*pooledToken{}}
+	for _, source := range sources {
+		pool.tokens = append(pool.tokens, &pooledToken{source: source, remaining: -1})
+	}
+	return pool
+}
+
+// Reads the github personal access tokens, GITHUB_TOKEN and the comma separated GITHUB_TOKENS
+func githubTokens() []string {
+	tokens := []string{}
+	seen := map[string]bool{"": true}
+	for _, token := range append([]string{os.Getenv("GITHUB_TOKEN")}, strings.Split(os.Getenv("GITHUB_TOKENS"), ",")...) {
+		token = strings.TrimSpace(token)
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// Returns the quota a token is known to have, tokens whose quota is unknown or was reset are assumed to have a full quota
+func (t *pooledToken) quota(now time.Time) int {
+	if t.remaining < 0 || !now.Before(t.resetAt) {
+		return int(^uint(0) >> 1)
+	}
+	return t.remaining
+}
+
+// Returns the tokens a request about the repositories of an owner can be sent with
+func (p *tokenPool) candidates(owner string) ([]*pooledToken, error) {
+	if p.app == nil {
+		return p.tokens, nil
+	}
+	if owner != "" {
+		installationID, installed, err := p.app.installationID(owner)
+		if err != nil {
+			if len(p.tokens) == 0 {
+				return nil, err
+			}
+			fmt.Println(err)
+		}
+		if installed {
+			return append([]*pooledToken{p.installationToken(installationID)}, p.tokens...), nil
+		}
+	}
+	if len(p.tokens) > 0 {
+		return p.tokens, nil
+	}
+
+	// Without personal access tokens, the requests that aren't about a repository use any installation
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	installations := []*pooledToken{}
+	for _, token := range p.installations {
+		installations = append(installations, token)
+	}
+	if len(installations) == 0 {
+		return nil, errors.New("the github app isn't installed on " + owner)
+	}
+	return installations, nil
+}
+
+// Returns the token of an installation of the app
+func (p *tokenPool) installationToken(installationID int64) *pooledToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token, exists := p.installations[installationID]
+	if !exists {
+		token = &pooledToken{source: p.app.tokenSource(installationID), remaining: -1}
+		p.installations[installationID] = token
+	}
+	return token
+}
+
+// Picks the token with the most remaining quota, its quota is used up by one until the response tells the actual one
+func (p *tokenPool) pick(candidates []*pooledToken) *pooledToken {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var best *pooledToken
+	for _, token := range candidates {
+		if best == nil || token.quota(now) > best.quota(now) {
+			best = token
+		}
+	}
+	if best != nil && best.remaining > 0 && now.Before(best.resetAt) {
+		best.remaining--
+	}
+	return best
+}
+
+// Saves the quota reported by the rate limit headers of a response
+func (p *tokenPool) record(token *pooledToken, header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	token.remaining = remaining
+	token.resetAt = time.Unix(reset, 0)
+}
+
+// RoundTrip sends a request with the token that has the most remaining quota
+func (p *tokenPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	owner, _ := req.Context().Value(ownerKey{}).(string)
+	candidates, err := p.candidates(owner)
+	if err != nil {
+		return nil, err
+	}
+	token := p.pick(candidates)
+	if token == nil {
+		return nil, errors.New("no github token is configured")
+	}
+	accessToken, err := token.source.Token()
+	if err != nil {
+		return nil, errors.WithMessage(err, "couldn't get a github token")
+	}
+
+	// RoundTrippers must not modify the request
+	authenticated := req.WithContext(req.Context())
+	authenticated.Header = make(http.Header, len(req.Header)+1)
+	for key, values := range req.Header {
+		authenticated.Header[key] = values
+	}
+	accessToken.SetAuthHeader(authenticated)
+
+	res, err := p.base.RoundTrip(authenticated)
+	if err != nil {
+		return nil, err
+	}
+	p.record(token, res.Header)
+	return res, nil
+}
+
+// rateLimit summarizes the quotas of the tokens a request about the repositories of an owner can be sent with.
+// The remaining quota is the largest one, so the worker only waits when all of them are exhausted, until the first
+// of them is reset. It's unknown while one of them hasn't been used since its quota was reset, since it's picked next.
+func (p *tokenPool) rateLimit(owner string) (*RateLimit, error) {
+	candidates, err := p.candidates(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	rateLimit := &RateLimit{Remaining: -1}
+	for _, token := range candidates {
+		if token.remaining < 0 || !now.Before(token.resetAt) {
+			return &RateLimit{Remaining: -1}, nil
+		}
+		if token.remaining > rateLimit.Remaining {
+			rateLimit.Remaining = token.remaining
+		}
+		if token.remaining < minRemainingQuota && (rateLimit.ResetAt.IsZero() || token.resetAt.Before(rateLimit.ResetAt)) {
+			rateLimit.ResetAt = token.resetAt
+		}
+	}
+	return rateLimit, nil
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func Test_GithubTokens(t *testing.T) {
+	defer os.Setenv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	defer os.Setenv("GITHUB_TOKENS", os.Getenv("GITHUB_TOKENS"))
+	os.Setenv("GITHUB_TOKEN", "first")
+	os.Setenv("GITHUB_TOKENS", "second, first,,third")
+
+	tokens := githubTokens()
+	if len(tokens) != 3 || tokens[0] != "first" || tokens[1] != "second" || tokens[2] != "third" {
+		t.Errorf("unexpected tokens %v", tokens)
+	}
+}
+
+func Test_TokenPool(t *testing.T) {
+	resetAt := time.Now().Add(time.Hour).Unix()
+	// The quota of every token goes down with each request
+	quotas := map[string]int{"Bearer low": 150, "Bearer high": 250}
+	used := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		used = append(used, token)
+		quotas[token] -= 100
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(quotas[token]))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(resetAt, 10))
+	}))
+	defer server.Close()
+
+	pool := newTokenPool(http.DefaultTransport, nil,
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "low"}),
+		oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "high"}),
+	)
+	client := &http.Client{Transport: pool}
+	get := func() {
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+	}
+
+	rateLimit := func() *RateLimit {
+		rateLimit, err := pool.rateLimit("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rateLimit
+	}
+
+	// Every token is used once before their quotas are known
+	get()
+	if rateLimit().Remaining != -1 {
+		t.Error("expected the quota to be unknown while a token wasn't used")
+	}
+	get()
+	if rateLimit := rateLimit(); rateLimit.Remaining != 150 {
+		t.Errorf("expected a remaining quota of 150, got %d", rateLimit.Remaining)
+	}
+
+	// The token with the most remaining quota is picked, the worker only waits once all the tokens are exhausted
+	get()
+	if used[2] != "Bearer high" {
+		t.Errorf("expected the token with the most quota to be used, got %s", used[2])
+	}
+	exhausted := rateLimit()
+	if exhausted.Remaining >= minRemainingQuota || exhausted.ResetAt.Unix() != resetAt {
+		t.Errorf("expected the tokens to be exhausted until %d, got %d %d", resetAt, exhausted.Remaining, exhausted.ResetAt.Unix())
+	}
+}
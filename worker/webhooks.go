@@ -0,0 +1,285 @@
+package worker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/nulls"
+	"github.com/ossn/fixme_backend/models"
+	"github.com/pkg/errors"
+)
+
+type (
+	/**
+	* GitHub webhook payload types
+	 */
+
+	WebhookLabel struct {
+		Name string `json:"name"`
+	}
+
+	WebhookIssue struct {
+		ID        int    `json:"id"`
+		Number    int    `json:"number"`
+		Title     string `json:"title"`
+		Body      string `json:"body"`
+		State     string `json:"state"`
+		HTMLURL   string `json:"html_url"`
+		CreatedAt string `json:"created_at"`
+		UpdatedAt string `json:"updated_at"`
+		Comments  int    `json:"comments"`
+		Reactions struct {
+			TotalCount int `json:"total_count"`
+		} `json:"reactions"`
+		Labels      []WebhookLabel `json:"labels"`
+		PullRequest *struct{}      `json:"pull_request"`
+	}
+
+	WebhookRepository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+		Language string `json:"language"`
+		Archived bool   `json:"archived"`
+	}
+
+	WebhookChanges struct {
+		Name *struct {
+			From string `json:"from"`
+		} `json:"name"`
+		Repository *struct {
+			Name *struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner *struct {
+			From struct {
+				User *struct {
+					Login string `json:"login"`
+				} `json:"user"`
+				Organization *struct {
+					Login string `json:"login"`
+				} `json:"organization"`
+			} `json:"from"`
+		} `json:"owner"`
+	}
+
+	// WebhookEvent is the subset of a GitHub webhook payload used by the worker
+	WebhookEvent struct {
+		Action     string            `json:"action"`
+		Issue      *WebhookIssue     `json:"issue"`
+		Label      *WebhookLabel     `json:"label"`
+		Repository WebhookRepository `json:"repository"`
+		Changes    *WebhookChanges   `json:"changes"`
+	}
+)
+
+// HandleWebhookEvent applies a GitHub webhook event to the tracked issues and repositories.
+// Events for repositories that aren't tracked are ignored.
+func (w *Worker) HandleWebhookEvent(eventType string, event *WebhookEvent) error {
+	var (
+		changed models.Repositories
+		err     error
+	)
+	switch eventType {
+	case "issues":
+		changed, err = w.handleIssueEvent(event)
+	case "label":
+		changed, err = w.handleLabelEvent(event)
+	case "repository":
+		changed, err = w.handleRepositoryEvent(event)
+	default:
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		repositories := []*models.Repository{}
+		for i := range changed {
+			repositories = append(repositories, &changed[i])
+		}
+		go invalidateRepositoriesCache(repositories...)
+	}
+	return nil
+}
+
+// Finds the tracked repositories that match a webhook repository
+func findWebhookRepositories(fullName string) (models.Repositories, error) {
+	repos := models.Repositories{}
+	if fullName == "" {
+		return repos, nil
+	}
+	fullName = strings.ToLower(fullName)
+	err := models.DB.Where("lower(trim(trailing '/' from repository_url)) in (?, ?)", "https://github.com/"+fullName, "http://github.com/"+fullName).All(&repos)
+	if err != nil {
+		return repos, errors.WithMessage(err, "failed to find repositories")
+	}
+	return repos, nil
+}
+
+// Each handler returns the repositories whose issues changed
+func (w *Worker) handleIssueEvent(event *WebhookEvent) (models.Repositories, error) {
+	if event.Issue == nil || event.Issue.PullRequest != nil {
+		return nil, nil
+	}
+	repos, err := findWebhookRepositories(event.Repository.FullName)
+	if err != nil || len(repos) == 0 {
+		return nil, err
+	}
+
+	webhookIssue := event.Issue
+	// Deleted and transferred issues are no longer part of the repository
+	closed := webhookIssue.State == "closed" || event.Action == "deleted" || event.Action == "transferred"
+	language := strings.ToLower(event.Repository.Language)
+	labels := []string{}
+	for _, label := range webhookIssue.Labels {
+		labels = append(labels, label.Name)
+	}
+
+	githubIssues := models.Issues{}
+	for _, repository := range repos {
+		githubIssue := models.Issue{
+			GithubID:        webhookIssue.ID,
+			Body:            nulls.String{String: webhookIssue.Body, Valid: webhookIssue.Body != ""},
+			Title:           nulls.String{String: webhookIssue.Title, Valid: webhookIssue.Title != ""},
+			Closed:          closed,
+			Number:          webhookIssue.Number,
+			URL:             webhookIssue.HTMLURL,
+			RepositoryID:    repository.ID,
+			ProjectID:       repository.ProjectID,
+			Language:        nulls.String{String: language, Valid: language != ""},
+			GithubUpdatedAt: timeConvert(webhookIssue.UpdatedAt),
+			GithubCreatedAt: nulls.Time{Time: timeConvert(webhookIssue.CreatedAt), Valid: webhookIssue.CreatedAt != ""},
+			CommentsCount:   webhookIssue.Comments,
+			ReactionsCount:  webhookIssue.Reactions.TotalCount,
+		}
+		applyLabels(&githubIssue, labels, "")
+		githubIssues = append(githubIssues, githubIssue)
+	}
+	saveIssues(githubIssues)
+
+	for i := range repos {
+		updateIssueCounts(&repos[i])
+	}
+	return repos, nil
+}
+
+func (w *Worker) handleLabelEvent(event *WebhookEvent) (models.Repositories, error) {
+	if event.Label == nil || (event.Action != "edited" && event.Action != "deleted") {
+		return nil, nil
+	}
+	oldName := event.Label.Name
+	if event.Action == "edited" {
+		if event.Changes == nil || event.Changes.Name == nil {
+			// Only the color or the description changed
+			return nil, nil
+		}
+		oldName = event.Changes.Name.From
+	}
+
+	repos, err := findWebhookRepositories(event.Repository.FullName)
+	if err != nil || len(repos) == 0 {
+		return nil, err
+	}
+
+	changed := models.Repositories{}
+	for _, repository := range repos {
+		issues := models.Issues{}
+		err := models.DB.Where("repository_id = ? and ? = any(labels)", repository.ID, oldName).All(&issues)
+		if err != nil {
+			return changed, errors.WithMessage(err, "failed to find labeled issues")
+		}
+
+		relabeled := false
+		for _, issue := range issues {
+			labels := []string{}
+			for _, label := range issue.Labels {
+				switch {
+				case label != oldName:
+					labels = append(labels, label)
+				case event.Action == "edited":
+					labels = append(labels, event.Label.Name)
+				}
+			}
+			applyLabels(&issue, labels, "")
+			verr, err := models.DB.ValidateAndUpdate(&issue)
+			if verr.HasAny() {
+				fmt.Println(verr.Error())
+				continue
+			}
+			if err != nil {
+				fmt.Println(errors.WithMessage(err, "failed to update issue labels"))
+				continue
+			}
+			relabeled = true
+		}
+		if relabeled {
+			changed = append(changed, repository)
+		}
+	}
+	return changed, nil
+}
+
+func (w *Worker) handleRepositoryEvent(event *WebhookEvent) (models.Repositories, error) {
+	fullName := event.Repository.FullName
+	switch event.Action {
+	case "renamed", "transferred":
+		fullName = previousFullName(event)
+	case "archived", "deleted", "privatized", "unarchived", "publicized":
+	default:
+		return nil, nil
+	}
+
+	repos, err := findWebhookRepositories(fullName)
+	if err != nil || len(repos) == 0 {
+		return nil, err
+	}
+
+	for i := range repos {
+		repository := &repos[i]
+		switch event.Action {
+		case "renamed", "transferred":
+			setRepositoryStatus(repository, models.RepositoryStatusActive, "The repository moved from "+repository.RepositoryUrl)
+			repository.RepositoryUrl = event.Repository.HTMLURL
+		case "archived", "deleted", "privatized":
+			// The issues of the repository can't be worked on anymore
+			if err := closeRepositoryIssues(repository); err != nil {
+				return nil, err
+			}
+			if event.Action == "archived" {
+				setRepositoryStatus(repository, models.RepositoryStatusArchived, "The repository is archived")
+			} else {
+				setRepositoryStatus(repository, models.RepositoryStatusBroken, "The repository was "+event.Action)
+			}
+		case "unarchived", "publicized":
+			setRepositoryStatus(repository, models.RepositoryStatusActive, "")
+			// Let the polling pick up the reopened issues as soon as possible
+			repository.LastParsed = time.Unix(0, 0)
+		}
+		// Saves the repository changes along with the new counts
+		updateIssueCounts(repository)
+	}
+	return repos, nil
+}
+
+// Builds the full name that a renamed or transferred repository had before the event
+func previousFullName(event *WebhookEvent) string {
+	parts := strings.SplitN(event.Repository.FullName, "/", 2)
+	if len(parts) < 2 || event.Changes == nil {
+		return event.Repository.FullName
+	}
+	owner, name := parts[0], parts[1]
+	if event.Changes.Repository != nil && event.Changes.Repository.Name != nil {
+		name = event.Changes.Repository.Name.From
+	}
+	if event.Changes.Owner != nil {
+		if from := event.Changes.Owner.From.User; from != nil {
+			owner = from.Login
+		}
+		if from := event.Changes.Owner.From.Organization; from != nil {
+			owner = from.Login
+		}
+	}
+	return owner + "/" + name
+}
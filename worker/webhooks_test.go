@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func Test_PreviousFullName(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected string
+	}{
+		{
+			"renamed",
+			`{"action":"renamed","repository":{"full_name":"ossn/fixme"},"changes":{"repository":{"name":{"from":"fixme_backend"}}}}`,
+			"ossn/fixme_backend",
+		},
+		{
+			"transferred from organization",
+			`{"action":"transferred","repository":{"full_name":"ossn/fixme_backend"},"changes":{"owner":{"from":{"organization":{"login":"mozilla"}}}}}`,
+			"mozilla/fixme_backend",
+		},
+		{
+			"no changes",
+			`{"action":"renamed","repository":{"full_name":"ossn/fixme_backend"}}`,
+			"ossn/fixme_backend",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &WebhookEvent{}
+			if err := json.Unmarshal([]byte(tt.payload), event); err != nil {
+				t.Fatal(err)
+			}
+			if name := previousFullName(event); name != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
@@ -2,128 +2,105 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net/url"
+	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/gofrs/uuid"
 	"github.com/ossn/fixme_backend/cache"
 	"github.com/ossn/fixme_backend/models"
 
 	"github.com/gobuffalo/nulls"
 	"github.com/pkg/errors"
-	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
 
 type (
 	Worker struct {
 		ctx context.Context
-	}
-
-	/**
-	* GraphQL Types
-	 */
-
-	PageInfo struct {
-		StartCursor     string
-		HasPreviousPage bool
-	}
-	Issues struct {
-		Nodes []struct {
-			Title      string
-			Body       string
-			Closed     bool
-			Number     int
-			URL        string
-			CreatedAt  string
-			UpdatedAt  string
-			DatabaseID int
-			Labels     struct {
-				Nodes []struct {
-					Name string
-				}
-			} `graphql:"labels(first:100)"`
-		}
-		PageInfo PageInfo
-	}
-
-	language struct {
-		Repository struct {
-			PrimaryLanguage struct {
-				Name string
-			}
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	initialIssueQuery struct {
-		Repository struct {
-			Issues Issues `graphql:"issues(last: 100)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	issueQueryWithBefore struct {
-		Repository struct {
-			Issues Issues `graphql:"issues(last: 100, before: $before)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	tagsQuery struct {
-		Repository struct {
-			RepositoryTopics struct {
-				Nodes []struct {
-					Topic struct {
-						Name string
-					}
-				}
-			} `graphql:"repositoryTopics(first: 100)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	issueStatusQuery struct {
-		Repository struct {
-			Issue struct {
-				Closed bool
-			} `graphql:"issue(number: $number)"`
-		} `graphql:"repository(owner: $owner, name: $name)"`
-	}
-
-	rateLimitQuery struct {
-		RateLimit struct {
-			Remaining int    `graphql:"remaining"`
-			ResetAt   string `graphql:"resetAt"`
-		} `graphql:"rateLimit"`
+		// fullSyncInterval is how often all the issues of a repository are requested instead of the updated ones
+		fullSyncInterval time.Duration
+		// syncJobTimeout is how long a single job may take
+		syncJobTimeout time.Duration
+		// jobMaxAttempts is the number of failures in a row after which a job is dead
+		jobMaxAttempts int
+		// jobRetryDelay is the delay before the first retry of a failed job
+		jobRetryDelay  time.Duration
+		topicsInterval time.Duration
+		scheduler      *scheduler
+
+		mu sync.Mutex
+		// rateLimits are the last quotas of the sources by host
+		rateLimits map[string]RateLimitStatus
+	}
+
+	// syncPass is the state of a single sync of the issues of a repository
+	syncPass struct {
+		source     IssueSource
+		ref        RepositoryRef
+		repository *models.Repository
+		language   string
+		// since is zero for full syncs
+		since time.Time
+		// issuesUpdatedAt is the latest update time of the received issues
+		issuesUpdatedAt time.Time
 	}
 )
 
 var (
-	client     *githubv4.Client
 	WorkerInst Worker
+	// AfterCacheFlush is called once the issues have been removed from the cache, so that the api can cache them again
+	AfterCacheFlush func()
 )
 
 func init() {
 	WorkerInst = Worker{}
 }
 
+// Init sets the worker up and starts the polling in the background. It returns once the scheduler and the sources
+// are ready, so it has to be called before the api serves the handlers that use the worker.
 func (w *Worker) Init(ctx context.Context, c <-chan os.Signal) {
 	w.ctx = ctx
-	token := os.Getenv("GITHUB_TOKEN")
-	var src oauth2.TokenSource
-	if len(token) < 1 {
-		panic("Please provide a github token")
+	w.fullSyncInterval = 24 * time.Hour
+	if interval, err := time.ParseDuration(os.Getenv("FULL_SYNC_INTERVAL")); err == nil {
+		w.fullSyncInterval = interval
+	}
+	w.syncJobTimeout = envDuration("SYNC_JOB_TIMEOUT", defaultSyncJobTimeout)
+	w.jobMaxAttempts = envInt("SYNC_MAX_ATTEMPTS", defaultJobMaxAttempts)
+	w.jobRetryDelay = envDuration("SYNC_RETRY_DELAY", defaultJobRetryDelay)
+	w.topicsInterval = envDuration("TOPICS_INTERVAL", defaultTopicsInterval)
+	w.scheduler = newScheduler(envInt("SYNC_WORKERS", defaultSyncWorkers), envInt("SYNC_QUEUE_SIZE", defaultSyncQueueSize), w.runJob)
+	if err := w.InitSources(ctx); err != nil {
+		panic(err.Error())
 	}
+	go w.startPolling(c)
+}
 
-	src = oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: token},
-	)
+// InitSources registers the forges the repositories are loaded from without starting the polling
+func (w *Worker) InitSources(ctx context.Context) error {
+	w.ctx = ctx
+	app, err := githubAppFromEnv()
+	if err != nil {
+		return err
+	}
+	// The personal access tokens are used for the repositories the app isn't installed on
+	tokens := githubTokens()
+	if len(tokens) < 1 && app == nil {
+		return errors.New("Please provide a github token or a github app")
+	}
 
-	httpClient := oauth2.NewClient(ctx, src)
+	tokenSources := []oauth2.TokenSource{}
+	for _, token := range tokens {
+		tokenSources = append(tokenSources, oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: token},
+		))
+	}
 
-	client = githubv4.NewClient(httpClient)
-	go w.startPolling(c)
+	sources["github.com"] = newPooledGithubSource(githubEndpoint, newTokenPool(http.DefaultTransport, app, tokenSources...))
+	registerSources()
+	return nil
 }
 
 func (w *Worker) startPolling(c <-chan os.Signal) {
@@ -132,204 +109,138 @@ func (w *Worker) startPolling(c <-chan os.Signal) {
 		<-c
 		os.Exit(1)
 	}()
-	// Start topics polling
-	go w.repositoryTopicsPolling()
+	// Start repository imports polling
+	go w.repositoryImportsPolling()
 
-	// Start issue polling
-	for {
-		w.getInitialIssues()
+	// Start the sync, topics and dangling issues jobs
+	if err := requeueInterruptedJobs(); err != nil {
+		fmt.Println(err)
 	}
+	w.scheduler.start()
+	w.scheduler.dispatch()
 }
 
-func (w *Worker) checkRateLimitStatus() (bool, time.Time, error) {
-	rateLimitQuery := rateLimitQuery{}
-	err := client.Query(w.ctx, &rateLimitQuery, nil)
+func (w *Worker) checkRateLimitStatus(ctx context.Context, source IssueSource, ref RepositoryRef) (bool, time.Time, error) {
+	rateLimitData, err := source.RateLimit(ctx, ref)
 	if err != nil {
-		fmt.Println(errors.WithMessage(err, "couldn't check the rate limit usage"))
-		return true, time.Time{}, errors.WithMessage(err, "couldn't check the rate limit usage")
-	}
-	rateLimitData := rateLimitQuery.RateLimit
-	resetAt, err := time.Parse(time.RFC3339, rateLimitData.ResetAt)
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "couldn't check the rate limit usage"))
-		return true, time.Time{}, errors.WithMessage(err, "couldn't check the rate limit usage")
+		fmt.Println(err)
+		return true, time.Time{}, err
 	}
-	if rateLimitData.Remaining < 100 {
-		return true, resetAt, nil
+	limited := rateLimitData.Remaining >= 0 && rateLimitData.Remaining < minRemainingQuota
+	w.recordRateLimit(source, rateLimitData, limited)
+	if limited {
+		return true, rateLimitData.ResetAt, nil
 	}
 	return false, time.Time{}, nil
 }
 
-// Func to start repo topics polling
-func (w *Worker) repositoryTopicsPolling() {
+// waitUntilLimitIsRefreshed: A function that waits until the next query to a source about a repository can be executed,
+// it returns the error of the context when it's cancelled meanwhile
+func (w *Worker) waitUntilLimitIsRefreshed(ctx context.Context, source IssueSource, ref RepositoryRef) error {
 	for {
-		go w.UpdateRepositoryTopics()
-		time.Sleep(1 * time.Hour)
-	}
-}
-
-// Get all the tags repositories and set them to the project
-func (w *Worker) UpdateRepositoryTopics() {
-	w.waitUntilLimitIsRefreshed()
-	repos := models.Repositories{}
-	err := models.DB.All(&repos)
-	if err != nil {
-		fmt.Println(errors.Wrap(err, "failed to get repos"))
-		return
-	}
-	repoIndexMap := make(map[uuid.UUID][]int, len(repos))
-	for i, repo := range repos {
-		repoIndexMap[repo.ProjectID] = append(repoIndexMap[repo.ProjectID], i)
-		name, owner, err := getNameAndOwner(repo.RepositoryUrl)
-		if err != nil {
-			continue
+		limitExceeded, resetAt, err := w.checkRateLimitStatus(ctx, source, ref)
+		if err == nil && !limitExceeded {
+			return nil
 		}
-		tags := tagsQuery{}
-		err = client.Query(w.ctx, &tags, map[string]interface{}{"name": name, "owner": owner})
+		delay := time.Until(resetAt)
 		if err != nil {
-			fmt.Println(errors.Wrap(err, "couldn't load repos from github"))
-			continue
+			// if there is an issue retry in 5 minutes
+			delay = time.Minute * 5
 		}
-
-		repoTags := []string{}
-		for _, tag := range tags.Repository.RepositoryTopics.Nodes {
-			repoTags = append(repoTags, tag.Topic.Name)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
 		}
-
-		repo.Tags = cleanupArray(repoTags)
-		verr, err := repo.Validate(models.DB)
-		if verr.HasAny() {
-			fmt.Println(verr.Error())
-			continue
-		}
-		if err != nil {
-			fmt.Println(errors.Wrap(err, "couldn't save repos from github"))
-			continue
-		}
-		repos[i] = repo
-	}
-	verr, err := models.DB.ValidateAndUpdate(&repos)
-	if err != nil || verr.HasAny() {
-		fmt.Println(err, verr.Error())
-	}
-
-	projects := models.Projects{}
-	err = models.DB.All(&projects)
-	if err != nil {
-		fmt.Println(errors.Wrap(err, "failed to get repos"))
-		return
-	}
-	for i, project := range projects {
-		repoIDs, exists := repoIndexMap[project.ID]
-		projectRepos := models.Repositories{}
-		if !exists {
-			err = models.DB.Where("project_id = ?", project.ID).All(&projectRepos)
-			if err != nil {
-				fmt.Println(errors.Wrap(err, "failed to find repos"))
-				continue
-			}
-		} else {
-			for _, index := range repoIDs {
-				projectRepos = append(projectRepos, repos[index])
-			}
-		}
-
-		tags := []string{}
-		for _, repo := range projectRepos {
-			tags = append(tags, repo.Tags...)
-		}
-
-		tags = cleanupArray(tags)
-		project.Tags = tags
-		verr, err := project.Validate(models.DB)
-		if verr.HasAny() {
-			fmt.Println(verr.Error())
-			continue
-		}
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "failed to save project"))
-			continue
-		}
-		projects[i] = project
-	}
-
-	verr, err = models.DB.ValidateAndUpdate(&repos)
-	if err != nil || verr.HasAny() {
-		fmt.Println(err, verr.Error())
 	}
 }
 
-// waitUntilLimitIsRefreshed: A function that waits until the next github query can be executed
-func (w *Worker) waitUntilLimitIsRefreshed() {
-	limitExceeded, resetAt, err := w.checkRateLimitStatus()
+// Returns a rateLimitedError when the next query to a source about a repository can't be executed,
+// so that the job gives its worker back instead of waiting for the rate limit to be reset
+func (w *Worker) checkRateLimit(ctx context.Context, source IssueSource, ref RepositoryRef) error {
+	limitExceeded, resetAt, err := w.checkRateLimitStatus(ctx, source, ref)
 	if err != nil {
-		// if there is an issue retry in 5 minutes
-		time.Sleep(time.Minute * 5)
-		w.waitUntilLimitIsRefreshed()
+		return err
 	}
 	if limitExceeded {
-		time.Sleep(time.Until(resetAt))
-		w.waitUntilLimitIsRefreshed()
+		return errors.WithStack(&rateLimitedError{resetAt: resetAt})
 	}
+	return nil
 }
 
-// Get first issues
-func (w *Worker) getInitialIssues() {
-	w.waitUntilLimitIsRefreshed()
-	lastUpdatedRepo := models.Repository{}
-	err := models.DB.Order("last_parsed asc").First(&lastUpdatedRepo)
-
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "failed to get issues"))
-		return
+// Syncs the issues of a repository
+func (w *Worker) syncRepository(ctx context.Context, source IssueSource, ref RepositoryRef, repository *models.Repository) error {
+	if inspector, ok := source.(RepositoryInspector); ok {
+		if syncable, err := w.checkRepository(ctx, inspector, repository, &ref); !syncable {
+			return err
+		}
 	}
 
-	name, owner, err := getNameAndOwner(lastUpdatedRepo.RepositoryUrl)
+	primaryLanguage, err := source.PrimaryLanguage(ctx, ref)
 	if err != nil {
-		return
-	}
-	variables := map[string]interface{}{"name": name, "owner": owner}
-	issueData := initialIssueQuery{}
-	err = client.Query(w.ctx, &issueData, variables)
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "couldn't load initial issues"))
-		return
+		return err
 	}
 
-	languageRequest := language{}
-	err = client.Query(w.ctx, &languageRequest, variables)
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "couldn't find language"))
-		return
+	pass := &syncPass{source: source, ref: ref, repository: repository, language: primaryLanguage}
+	// Only request the issues that changed since the last sync, unless a full reconciliation is due
+	if repository.IssuesUpdatedAt.Valid && time.Since(repository.LastFullSync) < w.fullSyncInterval {
+		// Overlap a bit in case of clock differences
+		pass.since = repository.IssuesUpdatedAt.Time.Add(-time.Minute)
 	}
-	hasPreviousPage := issueData.Repository.Issues.PageInfo.HasPreviousPage
-	go w.parseAndSaveIssues(issueQueryWithBefore(issueData), &lastUpdatedRepo, &languageRequest.Repository.PrimaryLanguage.Name, hasPreviousPage)
 
-	if hasPreviousPage {
-		w.getExtraIssues(&name, &owner, &issueData.Repository.Issues.PageInfo.StartCursor, &lastUpdatedRepo, &languageRequest.Repository.PrimaryLanguage.Name)
+	cursor := ""
+	for {
+		issuePage, err := w.listIssues(ctx, pass, cursor)
+		if err != nil {
+			// The pages that were saved already are listed again
+			if cursor != "" {
+				invalidateRepositoriesCache(repository)
+			}
+			return err
+		}
+
+		for _, issue := range issuePage.Issues {
+			if issue.UpdatedAt.After(pass.issuesUpdatedAt) {
+				pass.issuesUpdatedAt = issue.UpdatedAt
+			}
+		}
+		w.parseAndSaveIssues(issuePage.Issues, pass)
 
+		if issuePage.NextCursor == "" {
+			break
+		}
+		cursor = issuePage.NextCursor
 	}
 
+	// Move the high-water mark once all the pages have been received
+	if !pass.issuesUpdatedAt.IsZero() {
+		repository.IssuesUpdatedAt = nulls.NewTime(pass.issuesUpdatedAt)
+	}
+	if pass.full() {
+		repository.LastFullSync = time.Now()
+	}
+	w.updateProjectOnFinish(pass)
+	return nil
 }
 
-// Get next page of issues
-func (w *Worker) getExtraIssues(name, owner *githubv4.String, before *string, repository *models.Repository, language *string) {
-	w.waitUntilLimitIsRefreshed()
-	variables := map[string]interface{}{"name": *name, "owner": *owner, "before": githubv4.String(*before)}
-	issueData := issueQueryWithBefore{}
-	err := client.Query(w.ctx, &issueData, variables)
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "Failed to get additional issues"))
-		return
+// Requests a page of the issues of a sync unless the quota of the source is exhausted
+func (w *Worker) listIssues(ctx context.Context, pass *syncPass, cursor string) (*IssuePage, error) {
+	if err := w.checkRateLimit(ctx, pass.source, pass.ref); err != nil {
+		return nil, err
 	}
+	return pass.source.ListIssues(ctx, pass.ref, pass.since, cursor)
+}
 
-	hasPreviousPage := issueData.Repository.Issues.PageInfo.HasPreviousPage
-	go w.parseAndSaveIssues(issueData, repository, language, hasPreviousPage)
+func (p *syncPass) full() bool {
+	return p.since.IsZero()
+}
 
-	if hasPreviousPage {
-		w.getExtraIssues(name, owner, &issueData.Repository.Issues.PageInfo.StartCursor, repository, language)
+// Returns the separator of the scoped labels of the source, it's empty when the source doesn't have any
+func (p *syncPass) scopeSeparator() string {
+	if scoper, ok := p.source.(LabelScoper); ok {
+		return scoper.ScopeSeparator()
 	}
-
+	return ""
 }
 
 // Parse string to time.Time
@@ -342,12 +253,12 @@ func timeConvert(GHUpdatedTime string) time.Time {
 }
 
 // Parse and save github issues
-func (w *Worker) parseAndSaveIssues(issueData issueQueryWithBefore, repository *models.Repository, language *string, hasPreviousPage bool) {
-	issuesToCreate := models.Issues{}
-	issuesToUpdate := models.Issues{}
-	for _, node := range issueData.Repository.Issues.Nodes {
-		githubIssue := &models.Issue{
-			GithubID:        node.DatabaseID,
+func (w *Worker) parseAndSaveIssues(sourceIssues []SourceIssue, pass *syncPass) {
+	repository := pass.repository
+	githubIssues := models.Issues{}
+	for _, node := range sourceIssues {
+		githubIssue := models.Issue{
+			GithubID:        node.ID,
 			Body:            nulls.String{String: node.Body, Valid: node.Body != ""},
 			Title:           nulls.String{String: node.Title, Valid: node.Title != ""},
 			Closed:          node.Closed,
@@ -355,36 +266,28 @@ func (w *Worker) parseAndSaveIssues(issueData issueQueryWithBefore, repository *
 			URL:             node.URL,
 			RepositoryID:    repository.ID,
 			ProjectID:       repository.ProjectID,
-			Language:        nulls.String{String: strings.ToLower(*language), Valid: *language != ""},
-			GithubUpdatedAt: timeConvert(node.UpdatedAt),
+			Language:        nulls.String{String: strings.ToLower(pass.language), Valid: pass.language != ""},
+			GithubUpdatedAt: node.UpdatedAt,
+			GithubCreatedAt: nulls.Time{Time: node.CreatedAt, Valid: !node.CreatedAt.IsZero()},
+			CommentsCount:   node.Comments,
+			ReactionsCount:  node.Reactions,
 		}
 
-		// Parse github labels
-		labels := []string{}
-		for _, label := range node.Labels.Nodes {
-			name := &label.Name
-			labels = append(labels, *name)
-			// Search for known labels
-			matched := searchForMatchingLabels(name, githubIssue)
-			// Split name based on known delimeters
-			tmp := strings.FieldsFunc(*name, split)
-			// If label hasn't been matched try again with the splited string
-			if !matched && len(tmp) > 1 {
-				for _, label := range tmp {
-					searchForMatchingLabels(&label, githubIssue)
-				}
-			}
-		}
+		applyLabels(&githubIssue, append([]string{}, node.Labels...), pass.scopeSeparator())
+		githubIssues = append(githubIssues, githubIssue)
+	}
 
-		githubIssue.Labels = labels
-		// Initialize experience needed with moderate
-		if !githubIssue.ExperienceNeeded.Valid {
-			githubIssue.ExperienceNeeded = nulls.String{String: "moderate", Valid: true}
-		}
+	saveIssues(githubIssues)
+}
 
+// Creates the issues that don't exist yet and updates the rest, matching them by github id
+func saveIssues(githubIssues models.Issues) {
+	issuesToCreate := models.Issues{}
+	issuesToUpdate := models.Issues{}
+	for _, githubIssue := range githubIssues {
 		// Allocate empty issue
 		dbIssue := models.Issue{}
-		if err := models.DB.Where("github_id = ?", node.DatabaseID).First(&dbIssue); err != nil {
+		if err := models.DB.Where("repository_id = ? and github_id = ?", githubIssue.RepositoryID, githubIssue.GithubID).First(&dbIssue); err != nil {
 			verrs, err := githubIssue.Validate(models.DB)
 			if verrs.HasAny() {
 				fmt.Println(verrs.Error())
@@ -394,7 +297,7 @@ func (w *Worker) parseAndSaveIssues(issueData issueQueryWithBefore, repository *
 				fmt.Println(errors.WithMessage(err, "Issues isn't valid"))
 				continue
 			}
-			issuesToCreate = append(issuesToCreate, *githubIssue)
+			issuesToCreate = append(issuesToCreate, githubIssue)
 			continue
 		}
 		githubIssue.ID = dbIssue.ID
@@ -408,7 +311,7 @@ func (w *Worker) parseAndSaveIssues(issueData issueQueryWithBefore, repository *
 			fmt.Println(errors.WithMessage(err, "Issues isn't valid"))
 			continue
 		}
-		issuesToUpdate = append(issuesToUpdate, *githubIssue)
+		issuesToUpdate = append(issuesToUpdate, githubIssue)
 	}
 	// Create all the new issues
 	err := models.DB.Create(&issuesToCreate)
@@ -420,18 +323,28 @@ func (w *Worker) parseAndSaveIssues(issueData issueQueryWithBefore, repository *
 	if err != nil {
 		fmt.Println(errors.WithMessage(err, "failed to update issues"))
 	}
+}
 
-	go deleteAndUpdateCache()
-
-	// Update repo record once all the github issues have been parsed
-	if !hasPreviousPage {
-		w.updateProjectOnFinish(repository)
+// Update project info when issues have been updated
+func (w *Worker) updateProjectOnFinish(pass *syncPass) {
+	repository := pass.repository
+	// Issues that weren't updated by an incremental sync are expected to be unchanged,
+	// so deleted and transferred issues are only searched for after a full sync
+	if pass.full() {
+		if err := models.QueueSyncJobs(models.DB, models.SyncJobKindDangling, "repositories.id = ?", repository.ID); err != nil {
+			fmt.Println(errors.WithMessage(err, "failed to queue the dangling issues check"))
+		}
 	}
+
+	repository.LastParsed = time.Now()
+	updateIssueCounts(repository)
+
+	// The listings and the project detail, which shows the repositories along with their counts, are cached again once they are saved
+	invalidateRepositoriesCache(repository)
 }
 
-// Update project info when issues have been updated
-func (w *Worker) updateProjectOnFinish(repository *models.Repository) {
-	go w.searchForDanglingIssues(repository)
+// Recount the open issues of a repository and its project
+func updateIssueCounts(repository *models.Repository) {
 	var err error
 
 	repository.IssueCount, err = models.DB.Where("closed=false and repository_id=?", repository.ID).Count(&models.Issue{})
@@ -439,7 +352,6 @@ func (w *Worker) updateProjectOnFinish(repository *models.Repository) {
 		fmt.Println(errors.WithMessage(err, "Failed to count"))
 	}
 
-	repository.LastParsed = time.Now()
 	verr, err := models.DB.ValidateAndUpdate(repository)
 	if verr.HasAny() {
 		fmt.Println(verr.Error())
@@ -458,52 +370,68 @@ func (w *Worker) updateProjectOnFinish(repository *models.Repository) {
 		count += repo.IssueCount
 	}
 
-	project := &models.Project{}
-	if err = models.DB.Find(project, repository.ProjectID); err != nil {
-		fmt.Println(errors.WithMessage(err, "Failed to find project"))
-	}
-
-	project.IssuesCount = count
-	verr, err = models.DB.ValidateAndUpdate(project)
-	if verr.HasAny() {
-		fmt.Println(verr.Error())
-	}
+	// Only the count is saved, so that the jobs of the other repositories of the project aren't overwritten
+	err = models.DB.RawQuery("update projects set issues_count = ?, updated_at = ? where id = ?", count, time.Now(), repository.ProjectID).Exec()
 	if err != nil {
 		fmt.Println(errors.WithMessage(err, "Failed to update project"))
 	}
 }
 
-// Cleanup project issues that have been deleted or couldn't be found in the repo
-func (w *Worker) searchForDanglingIssues(repository *models.Repository) {
+const (
+	// danglingBatchSize is the number of issues whose state is checked with a single request
+	danglingBatchSize = 50
+	// danglingRetries is the number of times the states of a batch are requested again after transport errors
+	danglingRetries = 4
+)
+
+// danglingRetryDelay is the delay before the first retry of a batch, it doubles on every retry
+var danglingRetryDelay = 5 * time.Second
+
+// Close the issues that have been closed, deleted or transferred on the forge without the sync noticing.
+// Issues are only closed when the forge says so, the ones whose state can't be checked are left open.
+func (w *Worker) searchForDanglingIssues(ctx context.Context, source IssueSource, ref RepositoryRef, repository *models.Repository) error {
 	issues := models.Issues{}
-	name, owner, err := getNameAndOwner(repository.RepositoryUrl)
+	err := models.DB.Where("updated_at < current_timestamp - interval '6 minutes' and closed = false and repository_id = ?", repository.ID).All(&issues)
 	if err != nil {
-		return
-	}
-	err = models.DB.Where("updated_at < current_timestamp - interval '6 minutes' and closed = false and project_id = ?", repository.ProjectID).All(&issues)
-	if err != nil {
-		fmt.Println(errors.WithMessage(err, "Failed to find unclosed issues"))
-		return
+		return errors.WithMessage(err, "Failed to find unclosed issues")
 	}
+
 	issuesToClose := models.Issues{}
-	for _, issue := range issues {
-		issueStatus := issueStatusQuery{}
-		requestParams := map[string]interface{}{
-			"name": name, "owner": owner, "number": githubv4.Int(issue.Number)}
-		err = client.Query(w.ctx, &issueStatus, requestParams)
-		// This might close an issue if there is a network error
-		// but it's better to close an issue and reopen it later rather than leaving dangling issues
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "couldn't load issue from github "+string(owner)+" "+string(name)))
-			issue.Closed = true
-			issuesToClose = append(issuesToClose, issue)
-			continue
+	// checkErr is the last failure, the issues that could be checked are closed anyway
+	var checkErr error
+	for start := 0; start < len(issues); start += danglingBatchSize {
+		end := start + danglingBatchSize
+		if end > len(issues) {
+			end = len(issues)
+		}
+		batch := issues[start:end]
+		numbers := make([]int, len(batch))
+		for i, issue := range batch {
+			numbers[i] = issue.Number
 		}
 
-		if issueStatus.Repository.Issue.Closed {
-			issue.Closed = true
-			issuesToClose = append(issuesToClose, issue)
+		// The issues that were found closed so far are closed anyway
+		if err := w.checkRateLimit(ctx, source, ref); err != nil {
+			checkErr = err
+			break
+		}
+		states, err := w.issueStates(ctx, source, ref, numbers)
+		if err != nil {
+			checkErr = err
 		}
+		for _, issue := range batch {
+			if state, known := states[issue.Number]; known && state != IssueStateOpen {
+				issue.Closed = true
+				issuesToClose = append(issuesToClose, issue)
+			}
+		}
+		// None of the other batches can be checked either
+		if errors.Cause(err) == ErrRepositoryNotFound || ctx.Err() != nil {
+			break
+		}
+	}
+	if len(issuesToClose) == 0 {
+		return checkErr
 	}
 
 	verr, err := models.DB.ValidateAndUpdate(&issuesToClose)
@@ -511,43 +439,62 @@ func (w *Worker) searchForDanglingIssues(repository *models.Repository) {
 		fmt.Println(verr.Error())
 	}
 	if err != nil {
-		fmt.Println(errors.Wrap(err, "couldn't update issue"))
+		return errors.Wrap(err, "couldn't update issue")
 	}
+	updateIssueCounts(repository)
+	invalidateRepositoriesCache(repository)
+	return checkErr
 }
 
-/* Deletes cache issues and issue-count cached data. Then cache the default issues of the issues landing page */
-func deleteAndUpdateCache() {
-	cacheConn := cache.CachePool.Get()
-	defer cacheConn.Close()
-
-	cache.DeleteKeysByPattern(&cacheConn, "issues:*")
-	cache.DeleteKeysByPattern(&cacheConn, "issues-count:*")
-
-	params := url.Values{}
-	issues := &models.Issues{}
-	defaultIssuesWhereClause := "closed = false"
-	cacheKey := "issues:" + defaultIssuesWhereClause + " and page=1"
-	for _, filter := range []string{"language", "experience_needed", "type", "project_id", "ordering"} {
-		params.Set(filter, "undefined")
-	}
-	params.Set("page", "1")
-	query := models.DB.PaginateFromParams(params).Eager()
-	ok, _ := cache.Exists(&cacheConn, cacheKey)
-
-	if !ok {
-		if err := query.Where(defaultIssuesWhereClause).All(issues); err != nil {
-			fmt.Println(errors.WithMessage(err, "DB Operation falied"))
-			return
+// Requests the states of issues, requesting the unknown ones again after transport errors with a growing delay.
+// It returns the last error when some states are still unknown.
+func (w *Worker) issueStates(ctx context.Context, source IssueSource, ref RepositoryRef, numbers []int) (map[int]IssueState, error) {
+	states := map[int]IssueState{}
+	pending := numbers
+	delay := danglingRetryDelay
+	for attempt := 0; ; attempt++ {
+		received, err := source.IssueStates(ctx, ref, pending)
+		for number, state := range received {
+			states[number] = state
+		}
+		if err == nil {
+			return states, nil
+		}
+		fmt.Println(errors.WithMessage(err, "failed to check the issues of "+ref.FullName()))
+		if attempt == danglingRetries || errors.Cause(err) == ErrRepositoryNotFound {
+			return states, err
 		}
 
-		jsonIssues, err := json.Marshal(issues)
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache operation failed"))
-			return
+		unknown := []int{}
+		for _, number := range pending {
+			if _, known := states[number]; !known {
+				unknown = append(unknown, number)
+			}
 		}
-		_, err = cache.SetEx(&cacheConn, cacheKey, 600, string(jsonIssues))
-		if err != nil {
-			fmt.Println(errors.WithMessage(err, "Cache operation failed"))
+		if len(unknown) == 0 {
+			return states, nil
+		}
+		pending = unknown
+		select {
+		case <-ctx.Done():
+			return states, ctx.Err()
+		case <-time.After(delay):
 		}
+		delay *= 2
+	}
+}
+
+/* Invalidates the cached data built from the issues of the repositories. Then cache the default issues of the issues landing page */
+func invalidateRepositoriesCache(repositories ...*models.Repository) {
+	tags := []string{cache.IssuesTag}
+	for _, repository := range repositories {
+		tags = append(tags, cache.ProjectTag(repository.ProjectID.String()))
+	}
+	if _, err := cache.Backend.Invalidate(tags...); err != nil {
+		fmt.Println(errors.WithMessage(err, "cache invalidation failed"))
+	}
+
+	if AfterCacheFlush != nil {
+		AfterCacheFlush()
 	}
 }